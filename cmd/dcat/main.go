@@ -10,23 +10,30 @@ import (
 
 	"github.com/yznts/dsh/pkg/dconf"
 	"github.com/yznts/dsh/pkg/ddb"
+	_ "github.com/yznts/dsh/pkg/dext"
 	"github.com/yznts/dsh/pkg/dio"
 )
 
 // Tool flags
 var (
-	fdsn   = flag.String("dsn", "", "Database connection (can be set via DSN/DATABASE/DATABASE_URL env)")
-	fsql   = flag.Bool("sql", false, "Output in SQL format")
-	fcsv   = flag.Bool("csv", false, "Output in CSV format")
-	fjsonl = flag.Bool("jsonl", false, "Output in JSON lines format")
-	fwhere = flag.String("where", "", "WHERE clause")
+	fdsn     = flag.String("dsn", "", "Database connection (can be set via DSN/DATABASE/DATABASE_URL env)")
+	fconnect = flag.Duration("connect-timeout", ddb.DefaultOpenTimeout, "Connection timeout, transient network errors are retried until it's exceeded")
+	ftimeout = flag.Duration("timeout", 0, "Statement timeout (e.g. 30s), Ctrl-C cancels the statement as well")
+	fdbname  = flag.String("database", "", "Database to connect to, instead of the one in DSN (postgres and mysql)")
+	fschema  = flag.String("schema", "", "Default schema (search_path for postgres, database for mysql)")
+	fsql     = flag.Bool("sql", false, "Output in SQL format")
+	fcsv     = flag.Bool("csv", false, "Output in CSV format")
+	fjsonl   = flag.Bool("jsonl", false, "Output in JSON lines format")
+	fddl     = flag.Bool("ddl", false, "Precede data with typed CREATE TABLE statement (for SQL format)")
+	fwhere   = flag.String("where", "", "WHERE clause")
+	fargs    = dio.StringsFlag("arg", "WHERE clause argument, bound to the placeholder ($1 for postgres, ? for others), repeat for multiple")
 )
 
 // Tool usage / description
 var (
-	fusage = "[flags...] table"
+	fusage = "[flags...] [file.db] [schema.]table"
 	fdescr = "The dcat utility reads table data and writes it to the standard output in desired format. " +
-		"Because of chunked data fetching, output options might be limited. " +
+		"Because of streamed data fetching, output options might be limited. " +
 		"Utility tries to avoid accumulating data in the memory. " +
 		"If dcat output options are not enough and memory usage is not a concern, consider using dsql instead."
 )
@@ -43,6 +50,9 @@ var (
 // Simplify assignments
 var err error
 
+// Tool arguments (without database file, if provided as the first one)
+var args []string
+
 func main() {
 	// Provide usage
 	flag.Usage = dio.Usage(fusage, fdescr)
@@ -53,7 +63,7 @@ func main() {
 	// Resolve output writer.
 	//
 	// We are using only multiline writers here
-	// because we are going to query the database in chunks
+	// because we are going to stream the data from the database
 	// and write the result in chunks as well.
 	// Otherwise, we will have to store the whole result in memory.
 	//
@@ -74,67 +84,80 @@ func main() {
 		}
 	}
 
+	// Database file might be provided as the first argument
+	*fdsn, args = dconf.FileArg(*fdsn, flag.Args())
+
 	// Resolve dsn and database connection
 	dsn, err := dconf.GetDsn(*fdsn)
 	dio.Assert(stderr, err)
-	db, err = ddb.Open(dsn)
+	dsn, err = ddb.WithDatabase(dsn, *fdbname)
+	dio.Assert(stderr, err)
+	dsn, err = ddb.WithSchema(dsn, *fschema)
+	dio.Assert(stderr, err)
+	// Introspection doesn't modify the database,
+	// so file databases are opened read-only (unless mode is provided)
+	dsn, err = ddb.WithReadOnly(dsn)
+	dio.Assert(stderr, err)
+	connctx, conncancel := dio.Context(*fconnect)
+	db, err = ddb.OpenContext(connctx, dsn)
+	conncancel()
 	dio.Assert(stderr, err)
 	if db, iscloser := db.(io.Closer); iscloser {
 		defer db.Close()
 	}
 
-	// Extract table name from arguments
-	table := flag.Arg(0)
-	if table == "" {
+	// Resolve statement context,
+	// canceled on timeout or interrupt.
+	ctx, cancel := dio.Context(*ftimeout)
+	defer cancel()
+
+	// Extract table identifier from arguments
+	if len(args) == 0 || args[0] == "" {
 		dio.Assert(stderr, errors.New("missing table name"))
 	}
-
-	// Get rows count
-	data, err := db.QueryData(fmt.Sprintf("SELECT COUNT(*) FROM %s", table))
+	table, err := ddb.ParseTableIdent(args[0])
 	dio.Assert(stderr, err)
-	count := int(data.Rows[0][0].(int64))
+	// Quote table identifier to use it in queries
+	tablequoted := table.Quoted(db.QuoteIdent)
 
-	// If the total rows count is less than 1k, we can get back to non-limited mode.
-	if count < 1000 {
-		limited = false
+	// If writer is SQL, we're setting appropriate mode and table name
+	if stdout, ok := stdout.(*dio.Sql); ok {
+		stdout.SetMode("data")
+		stdout.SetTable(tablequoted)
+		stdout.SetQuote(db.QuoteIdent)
+		stdout.SetDDL(*fddl)
 	}
 
-	// Make offsets list
-	offsets := []int{}
-	for offset := 0; offset < count; offset += 1000 {
-		offsets = append(offsets, offset)
+	// Compose query with WHERE clause
+	query := &strings.Builder{}
+	query.WriteString(fmt.Sprintf("SELECT * FROM %s ", tablequoted))
+	if *fwhere != "" {
+		query.WriteString(fmt.Sprintf("WHERE %s ", *fwhere))
 	}
 
-	// If we are limited, we need only first chunk.
-	// Also, we need to warn the user about it.
+	// If we are limited, we need only first 1k rows.
+	// Also, we need to warn the user about it (if there are more rows).
 	if limited {
-		offsets = offsets[:1]
-		if stdout, warner := stdout.(dio.WarningWriter); warner {
-			stdout.WriteWarning("output is limited to 1k rows")
+		// Get rows count
+		data, err := db.QueryDataContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", tablequoted))
+		dio.Assert(stderr, err)
+		count := int(data.Rows[0][0].(int64))
+		// Limit the query and warn the user
+		query.WriteString("LIMIT 1000")
+		if count > 1000 {
+			if stdout, warner := stdout.(dio.WarningWriter); warner {
+				stdout.WriteWarning("output is limited to 1k rows")
+			}
 		}
 	}
 
-	// If writer is SQL, we're setting appropriate mode and table name
-	if stdout, ok := stdout.(*dio.Sql); ok {
-		stdout.SetMode("data")
-		stdout.SetTable(table)
-	}
+	// Execute query
+	stream, err := db.QueryStreamContext(ctx, query.String(), fargs.Any()...)
+	dio.Assert(stderr, err)
 
-	// Iterate over chunks and query the database
-	for _, offset := range offsets {
-		// Compose limit/offset query with WHERE clause
-		query := &strings.Builder{}
-		query.WriteString(fmt.Sprintf("SELECT * FROM %s ", table))
-		if *fwhere != "" {
-			query.WriteString(fmt.Sprintf("WHERE %s ", *fwhere))
-		}
-		query.WriteString(fmt.Sprintf("LIMIT 1000 OFFSET %d", offset))
-		// Execute query
-		data, err := db.QueryData(query.String())
-		dio.Assert(stderr, err)
-		// Don't collect the data and just write it to the output,
-		// because we don't want to keep it in memory.
-		// That's why we are requiring closable writers here.
-		stdout.WriteData(data)
-	}
+	// Don't collect the data and just write it to the output chunk by chunk,
+	// because we don't want to keep it in memory.
+	// That's why we are requiring multiline writers here.
+	err = stdout.WriteStream(stream)
+	dio.Assert(stderr, err)
 }
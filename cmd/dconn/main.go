@@ -2,17 +2,21 @@ package main
 
 import (
 	"flag"
+	"io"
 	"os"
 
 	"github.com/yznts/dsh/pkg/dconf"
 	"github.com/yznts/dsh/pkg/ddb"
+	_ "github.com/yznts/dsh/pkg/dext"
 	"github.com/yznts/dsh/pkg/dio"
 )
 
 // Tool flags
 var (
-	fdsn = flag.String("dsn", "", "Database connection (can be set via DSN/DATABASE/DATABASE_URL env)")
-	frpc = flag.String("rpc", ":25123", "RPC server address to listen on")
+	fdsn     = flag.String("dsn", "", "Database connection (can be set via DSN/DATABASE/DATABASE_URL env)")
+	fconnect = flag.Duration("connect-timeout", ddb.DefaultOpenTimeout, "Connection timeout, transient network errors are retried until it's exceeded")
+	frpc     = flag.String("rpc", ":25123", "RPC server address to listen on")
+	fjsonl   = flag.Bool("jsonl", false, "Output in JSON lines format (used by the client to restore errors)")
 )
 
 // Tool usage / description
@@ -26,9 +30,6 @@ var (
 		"It might be useful for cases when driver is not supported, or you don't want to import a driver at all for some reason."
 )
 
-// Database connection
-var db ddb.Database
-
 // Output writers
 var (
 	stdout dio.Writer
@@ -46,15 +47,23 @@ func main() {
 	flag.Parse()
 
 	// Resolve output writer
-	stdout = dio.Open(os.Stdout)
-	stderr = dio.Open(os.Stderr)
+	stdout = dio.Open(os.Stdout, false, false, false, *fjsonl)
+	stderr = dio.Open(os.Stderr, false, false, false, *fjsonl)
 
 	// Resolve dsn and database connection
 	dsn, err := dconf.GetDsn(*fdsn)
 	dio.Assert(stderr, err)
-	db, err = ddb.Open(dsn)
+	connctx, conncancel := dio.Context(*fconnect)
+	db, err := ddb.OpenContext(connctx, dsn)
+	conncancel()
 	dio.Assert(stderr, err)
 
+	// Connection is opened to validate it and report errors only.
+	// Each client gets its own one (see rpcserve).
+	if db, iscloser := db.(io.Closer); iscloser {
+		db.Close()
+	}
+
 	// Start rpc server
-	rpcserver(*frpc).Await()
+	rpcserver(*frpc, dsn).Await()
 }
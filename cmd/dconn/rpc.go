@@ -1,26 +1,100 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"io"
 	"net"
 	"net/rpc"
+	"sync"
 
 	"github.com/yznts/dsh/pkg/ddb"
 	"go.kyoto.codes/zen/v3/async"
 )
 
+// RpcQueryArgs holds arguments for Rpc.QueryData, Rpc.QueryStream and Rpc.Execute.
+type RpcQueryArgs struct {
+	Id    int64
+	Query string
+	Args  []any
+}
+
+// RpcTableArgs holds arguments for table-scoped methods,
+// like Rpc.QueryColumns, Rpc.QueryIndexes and Rpc.QueryConstraints.
+type RpcTableArgs struct {
+	Id    int64
+	Table ddb.TableIdent
+}
+
 // RpcKillProcessArgs holds arguments for Rpc.KillProcess.
 type RpcKillProcessArgs struct {
+	Id    int64
 	Pid   int
 	Force bool
 }
 
+// RpcStream holds a reply for Rpc.QueryStream and Rpc.StreamNextResultSet.
+// Client is using the call id to pull the stream chunks.
+type RpcStream struct {
+	Cols  []string
+	Types []ddb.ColumnType
+	More  bool // Whether the next result set is available (Rpc.StreamNextResultSet only)
+}
+
 // Rpc provides a set of RPC-compatible wrap methods
 // around ddb.Database.
-type Rpc struct{}
+//
+// Each client connection is served by its own Rpc (see rpcserve),
+// so the state below is never shared between clients.
+// Each call is identified by a client-provided id,
+// so the client is able to cancel it with Rpc.Cancel.
+type Rpc struct {
+	// Database connection of the client.
+	// It's a separate one for each client,
+	// so transactions are scoped to the client as well.
+	db ddb.Database
+
+	// Rpc methods are called concurrently,
+	// so access must be synchronized.
+	mu sync.Mutex
+	// Cancel functions of in-flight calls.
+	cancels map[int64]context.CancelFunc
+	// Opened streams, waiting to be pulled by the client.
+	streams map[int64]ddb.Stream
+}
+
+// context creates a cancelable context for the call with the given id.
+// Returned function must be called when the call is done.
+func (s *Rpc) context(id int64) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.cancels[id] = cancel
+	s.mu.Unlock()
+	return ctx, func() {
+		s.mu.Lock()
+		delete(s.cancels, id)
+		s.mu.Unlock()
+		cancel()
+	}
+}
+
+// Cancel cancels the call with the given id.
+// It's used by the client on context cancellation.
+func (s *Rpc) Cancel(id int64, res *bool) error {
+	s.mu.Lock()
+	cancel, ok := s.cancels[id]
+	s.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return nil
+}
 
 // QueryData is a wrap method around ddb.Database.QueryData.
-func (s *Rpc) QueryData(query string, res *ddb.Data) error {
-	data, err := db.QueryData(query)
+func (s *Rpc) QueryData(args RpcQueryArgs, res *ddb.Data) error {
+	ctx, done := s.context(args.Id)
+	defer done()
+	data, err := s.db.QueryDataContext(ctx, args.Query, args.Args...)
 	if err != nil {
 		return err
 	}
@@ -28,9 +102,102 @@ func (s *Rpc) QueryData(query string, res *ddb.Data) error {
 	return nil
 }
 
+// QueryStream is a wrap method around ddb.Database.QueryStream.
+// It opens a stream and holds it until the client closes it.
+// Stream context lives until the stream is closed as well.
+func (s *Rpc) QueryStream(args RpcQueryArgs, res *RpcStream) error {
+	ctx, done := s.context(args.Id)
+	stream, err := s.db.QueryStreamContext(ctx, args.Query, args.Args...)
+	if err != nil {
+		done()
+		return err
+	}
+	s.mu.Lock()
+	s.streams[args.Id] = &rpcStream{stream, done}
+	s.mu.Unlock()
+	*res = RpcStream{Cols: stream.Cols(), Types: stream.Types()}
+	return nil
+}
+
+// StreamNext pulls the next chunk of the stream, opened with Rpc.QueryStream.
+// Empty chunk means there are no more rows.
+func (s *Rpc) StreamNext(id int64, res *ddb.Data) error {
+	s.mu.Lock()
+	stream, ok := s.streams[id]
+	s.mu.Unlock()
+	if !ok {
+		return errors.New("stream not found")
+	}
+	if !stream.Next() {
+		*res = ddb.Data{Cols: stream.Cols(), Types: stream.Types()}
+		return stream.Err()
+	}
+	*res = *stream.Data()
+	return nil
+}
+
+// StreamNextResultSet advances the stream, opened with Rpc.QueryStream,
+// to the next result set and replies with its columns.
+func (s *Rpc) StreamNextResultSet(id int64, res *RpcStream) error {
+	s.mu.Lock()
+	stream, ok := s.streams[id]
+	s.mu.Unlock()
+	if !ok {
+		return errors.New("stream not found")
+	}
+	if !stream.NextResultSet() {
+		return stream.Err()
+	}
+	*res = RpcStream{Cols: stream.Cols(), Types: stream.Types(), More: true}
+	return nil
+}
+
+// StreamClose closes the stream, opened with Rpc.QueryStream.
+func (s *Rpc) StreamClose(id int64, res *bool) error {
+	s.mu.Lock()
+	stream, ok := s.streams[id]
+	delete(s.streams, id)
+	s.mu.Unlock()
+	if !ok {
+		return errors.New("stream not found")
+	}
+	return stream.Close()
+}
+
+// Execute is a wrap method around ddb.Database.Execute.
+func (s *Rpc) Execute(args RpcQueryArgs, res *ddb.Result) error {
+	ctx, done := s.context(args.Id)
+	defer done()
+	result, err := s.db.ExecuteContext(ctx, args.Query, args.Args...)
+	if err != nil {
+		return err
+	}
+	*res = *result
+	return nil
+}
+
+// Begin is a wrap method around ddb.Database.Begin.
+// Transaction must outlive the call,
+// so it's not bound to the call context (it can't be canceled).
+func (s *Rpc) Begin(id int64, res *bool) error {
+	return s.db.Begin()
+}
+
+// Commit is a wrap method around ddb.Database.Commit.
+func (s *Rpc) Commit(id int64, res *bool) error {
+	return s.db.Commit()
+}
+
+// Rollback is a wrap method around ddb.Database.Rollback.
+func (s *Rpc) Rollback(id int64, res *bool) error {
+	return s.db.Rollback()
+}
+
 // QueryTables is a wrap method around ddb.Database.QueryTables.
-func (s *Rpc) QueryTables(empty string, res *[]ddb.Table) error {
-	tables, err := db.QueryTables()
+func (s *Rpc) QueryTables(id int64, res *[]ddb.Table) error {
+	ctx, done := s.context(id)
+	defer done()
+	tables, err := s.db.QueryTablesContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -39,8 +206,10 @@ func (s *Rpc) QueryTables(empty string, res *[]ddb.Table) error {
 }
 
 // QueryColumns is a wrap method around ddb.Database.QueryColumns.
-func (s *Rpc) QueryColumns(table string, res *[]ddb.Column) error {
-	columns, err := db.QueryColumns(table)
+func (s *Rpc) QueryColumns(args RpcTableArgs, res *[]ddb.Column) error {
+	ctx, done := s.context(args.Id)
+	defer done()
+	columns, err := s.db.QueryColumnsContext(ctx, args.Table)
 	if err != nil {
 		return err
 	}
@@ -48,9 +217,59 @@ func (s *Rpc) QueryColumns(table string, res *[]ddb.Column) error {
 	return nil
 }
 
+// QueryIndexes is a wrap method around ddb.Database.QueryIndexes.
+func (s *Rpc) QueryIndexes(args RpcTableArgs, res *[]ddb.Index) error {
+	ctx, done := s.context(args.Id)
+	defer done()
+	indexes, err := s.db.QueryIndexesContext(ctx, args.Table)
+	if err != nil {
+		return err
+	}
+	*res = indexes
+	return nil
+}
+
+// QueryConstraints is a wrap method around ddb.Database.QueryConstraints.
+func (s *Rpc) QueryConstraints(args RpcTableArgs, res *[]ddb.Constraint) error {
+	ctx, done := s.context(args.Id)
+	defer done()
+	constraints, err := s.db.QueryConstraintsContext(ctx, args.Table)
+	if err != nil {
+		return err
+	}
+	*res = constraints
+	return nil
+}
+
+// QueryTableStats is a wrap method around ddb.Database.QueryTableStats.
+func (s *Rpc) QueryTableStats(id int64, res *[]ddb.TableStats) error {
+	ctx, done := s.context(id)
+	defer done()
+	stats, err := s.db.QueryTableStatsContext(ctx)
+	if err != nil {
+		return err
+	}
+	*res = stats
+	return nil
+}
+
+// QueryDatabases is a wrap method around ddb.Database.QueryDatabases.
+func (s *Rpc) QueryDatabases(id int64, res *[]ddb.DatabaseInfo) error {
+	ctx, done := s.context(id)
+	defer done()
+	databases, err := s.db.QueryDatabasesContext(ctx)
+	if err != nil {
+		return err
+	}
+	*res = databases
+	return nil
+}
+
 // QueryProcesses is a wrap method around ddb.Database.QueryProcesses.
-func (s *Rpc) QueryProcesses(empty string, res *[]ddb.Process) error {
-	processes, err := db.QueryProcesses()
+func (s *Rpc) QueryProcesses(id int64, res *[]ddb.Process) error {
+	ctx, done := s.context(id)
+	defer done()
+	processes, err := s.db.QueryProcessesContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -58,25 +277,126 @@ func (s *Rpc) QueryProcesses(empty string, res *[]ddb.Process) error {
 	return nil
 }
 
+// QueryLocks is a wrap method around ddb.Database.QueryLocks.
+func (s *Rpc) QueryLocks(id int64, res *[]ddb.Lock) error {
+	ctx, done := s.context(id)
+	defer done()
+	locks, err := s.db.QueryLocksContext(ctx)
+	if err != nil {
+		return err
+	}
+	*res = locks
+	return nil
+}
+
+// QueryRoles is a wrap method around ddb.Database.QueryRoles.
+func (s *Rpc) QueryRoles(id int64, res *[]ddb.Role) error {
+	ctx, done := s.context(id)
+	defer done()
+	roles, err := s.db.QueryRolesContext(ctx)
+	if err != nil {
+		return err
+	}
+	*res = roles
+	return nil
+}
+
+// QueryGrants is a wrap method around ddb.Database.QueryGrants.
+func (s *Rpc) QueryGrants(id int64, res *[]ddb.Grant) error {
+	ctx, done := s.context(id)
+	defer done()
+	grants, err := s.db.QueryGrantsContext(ctx)
+	if err != nil {
+		return err
+	}
+	*res = grants
+	return nil
+}
+
 // KillProcess is a wrap method around ddb.Database.KillProcess.
 func (s *Rpc) KillProcess(args RpcKillProcessArgs, res *bool) error {
-	err := db.KillProcess(args.Pid, args.Force)
+	ctx, done := s.context(args.Id)
+	defer done()
+	err := s.db.KillProcessContext(ctx, args.Pid, args.Force)
 	if err != nil {
 		*res = false
 	}
 	return err
 }
 
+// rpcStream wraps ddb.Stream to release its call context on close.
+type rpcStream struct {
+	ddb.Stream
+	done func()
+}
+
+func (s *rpcStream) Close() error {
+	defer s.done()
+	return s.Stream.Close()
+}
+
+// close releases the client state after disconnect.
+// In-flight calls are canceled, streams are closed,
+// and the active transaction (if any) is rolled back before closing the database.
+func (s *Rpc) close() {
+	s.mu.Lock()
+	for _, cancel := range s.cancels {
+		cancel()
+	}
+	streams := s.streams
+	s.streams = map[int64]ddb.Stream{}
+	s.mu.Unlock()
+	for _, stream := range streams {
+		stream.Close()
+	}
+	// Rollback fails if there is no transaction, it's fine
+	s.db.Rollback()
+	if db, iscloser := s.db.(io.Closer); iscloser {
+		db.Close()
+	}
+}
+
 // rpcserver starts an RPC server on the given address.
-func rpcserver(addr string) *async.Future[bool] {
+// Each client connection is served separately (see rpcserve).
+func rpcserver(addr string, dsn string) *async.Future[bool] {
 	return async.New(func() (bool, error) {
 		ln, err := net.Listen("tcp", addr)
 		if err != nil {
 			panic(err)
 		}
-		server := &Rpc{}
-		rpc.Register(server)
-		rpc.Accept(ln)
-		return false, nil
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return false, err
+			}
+			go rpcserve(conn, dsn)
+		}
 	})
 }
+
+// rpcserve serves a single client connection until it's closed.
+// Client gets its own database connection and Rpc state,
+// so call ids, streams and transactions of different clients never mix.
+func rpcserve(conn net.Conn, dsn string) {
+	defer conn.Close()
+	// Open the client database connection.
+	// Connection was already validated on start,
+	// so we're not reporting errors here, client just gets disconnected.
+	connctx, conncancel := context.WithTimeout(context.Background(), ddb.DefaultOpenTimeout)
+	db, err := ddb.OpenContext(connctx, dsn)
+	conncancel()
+	if err != nil {
+		return
+	}
+	// Serve the client
+	s := &Rpc{
+		db:      db,
+		cancels: map[int64]context.CancelFunc{},
+		streams: map[int64]ddb.Stream{},
+	}
+	server := rpc.NewServer()
+	server.Register(s)
+	server.ServeConn(conn)
+	// Release the client state
+	s.close()
+}
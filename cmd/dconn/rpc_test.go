@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yznts/dsh/pkg/ddb"
+)
+
+// testRpc composes the client state,
+// served by a temporary sqlite database.
+func testRpc(t *testing.T) *Rpc {
+	t.Helper()
+	db, err := ddb.Open("sqlite://" + filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Rpc{
+		db:      db,
+		cancels: map[int64]context.CancelFunc{},
+		streams: map[int64]ddb.Stream{},
+	}
+}
+
+// pending reports whether the call with the given id is in-flight.
+func (s *Rpc) pending(id int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.cancels[id]
+	return ok
+}
+
+func TestRpcContext(t *testing.T) {
+	s := testRpc(t)
+	defer s.close()
+	ctx1, done1 := s.context(1)
+	ctx2, done2 := s.context(2)
+	defer done2()
+	// Cancel affects the given call only
+	s.Cancel(1, new(bool))
+	if ctx1.Err() == nil || ctx2.Err() != nil {
+		t.Fatalf("unexpected contexts state: %v, %v", ctx1.Err(), ctx2.Err())
+	}
+	// Finished call is forgotten, unknown ids are ignored
+	done1()
+	if s.pending(1) || !s.pending(2) {
+		t.Fatal("finished call must be removed, in-flight one must be kept")
+	}
+	if err := s.Cancel(1, new(bool)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRpcCancel(t *testing.T) {
+	s := testRpc(t)
+	defer s.close()
+	// Run an endless query and cancel it, once it's in-flight
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.QueryData(RpcQueryArgs{
+			Id:    7,
+			Query: "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT COUNT(*) FROM c",
+		}, &ddb.Data{})
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for !s.pending(7) {
+		if time.Now().After(deadline) {
+			t.Fatal("query is not started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s.Cancel(7, new(bool))
+	select {
+	case err := <-errs:
+		// Driver doesn't wrap the context error, so we're checking the message
+		if err == nil || !strings.Contains(err.Error(), "canceled") {
+			t.Fatalf("expected cancellation error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("query is not canceled")
+	}
+	if s.pending(7) {
+		t.Fatal("canceled call must be removed")
+	}
+}
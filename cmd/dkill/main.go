@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"io"
+	"net/url"
 	"os"
 	"regexp"
 	"strconv"
@@ -10,25 +12,30 @@ import (
 
 	"github.com/yznts/dsh/pkg/dconf"
 	"github.com/yznts/dsh/pkg/ddb"
+	_ "github.com/yznts/dsh/pkg/dext"
 	"github.com/yznts/dsh/pkg/dio"
 	"go.kyoto.codes/zen/v3/slice"
 )
 
 // Tool flags
 var (
-	fdsn    = flag.String("dsn", "", "Database connection (can be set via DSN/DATABASE/DATABASE_URL env)")
-	fforce  = flag.Bool("force", false, "Terminate the process, instead of graceful shutdown")
-	fexceed = flag.Bool("exceed", false, "We're killing all processes exceeding a provided duration (Go time.Duration format)")
-	fquery  = flag.Bool("query", false, "We're killing all processes for query regex")
-	fuser   = flag.Bool("user", false, "We're killing all processes for username")
-	fpid    = flag.Bool("pid", false, "We're killing a process by PID (default)")
-	fdb     = flag.Bool("db", false, "We're killing all processes for database")
+	fdsn     = flag.String("dsn", "", "Database connection (can be set via DSN/DATABASE/DATABASE_URL env)")
+	fconnect = flag.Duration("connect-timeout", ddb.DefaultOpenTimeout, "Connection timeout, transient network errors are retried until it's exceeded")
+	ftimeout = flag.Duration("timeout", 0, "Statement timeout (e.g. 30s), Ctrl-C cancels the statement as well")
+	fforce   = flag.Bool("force", false, "Terminate the process, instead of graceful shutdown")
+	fexceed  = flag.Bool("exceed", false, "We're killing all processes exceeding a provided duration (Go time.Duration format)")
+	fquery   = flag.Bool("query", false, "We're killing all processes for query regex")
+	fuser    = flag.Bool("user", false, "We're killing all processes for username")
+	fpid     = flag.Bool("pid", false, "We're killing a process by PID (default)")
+	fdb      = flag.Bool("db", false, "We're killing all processes for database")
 )
 
 // Tool usage / description
 var (
-	fusage = "[flags...] <pid|duration|query|username|database>"
-	fdescr = "The dkill utility kills processes, depending on the flag and argument provided."
+	fusage = "[flags...] [file.db] <pid|duration|query|username|database>"
+	fdescr = "The dkill utility kills processes, depending on the flag and argument provided. " +
+		"For SQLite, processes are the applications holding the database file, " +
+		"so killing by duration (-exceed) is not supported: it's the process running time, not a query one."
 )
 
 // Database connection
@@ -43,6 +50,9 @@ var (
 // Simplify assignments
 var err error
 
+// Tool arguments (without database file, if provided as the first one)
+var args []string
+
 func main() {
 	// Provide usage
 	flag.Usage = dio.Usage(fusage, fdescr)
@@ -54,38 +64,57 @@ func main() {
 	stdout = dio.Open(os.Stdout, false, false, false)
 	stderr = dio.Open(os.Stderr, false, false, false)
 
+	// Database file might be provided as the first argument
+	*fdsn, args = dconf.FileArg(*fdsn, flag.Args())
+
 	// Resolve dsn and database connection
 	dsn, err := dconf.GetDsn(*fdsn)
 	dio.Assert(stderr, err)
-	db, err = ddb.Open(dsn)
+	connctx, conncancel := dio.Context(*fconnect)
+	db, err = ddb.OpenContext(connctx, dsn)
+	conncancel()
 	dio.Assert(stderr, err)
 	if db, iscloser := db.(io.Closer); iscloser {
 		defer db.Close()
 	}
 
+	// Resolve statement context,
+	// canceled on timeout or interrupt.
+	ctx, cancel := dio.Context(*ftimeout)
+	defer cancel()
+
 	// Query the database for the currently running processes
-	processes, err := db.QueryProcesses()
+	processes, err := db.QueryProcessesContext(ctx)
 	dio.Assert(stderr, err)
 
 	// Find out processes to kill
+	arg := ""
+	if len(args) > 0 {
+		arg = args[0]
+	}
 	kill := []ddb.Process{}
 	switch {
 	case *fpid:
-		pid, err := strconv.Atoi(flag.Arg(0))
+		pid, err := strconv.Atoi(arg)
 		dio.Assert(stderr, err, "provided PID is not a number")
 		kill = slice.Filter(processes, func(p ddb.Process) bool {
 			return p.Pid == pid
 		})
 		break
 	case *fexceed:
-		dur, err := time.ParseDuration(flag.Arg(0))
+		// SQLite process duration is the application running time,
+		// so long-running applications would be killed instead of long queries.
+		if dsnurl, err := url.Parse(dsn); err == nil && slice.Contains([]string{"sqlite", "sqlite3"}, dsnurl.Scheme) {
+			dio.Assert(stderr, errors.New("killing by duration is not supported for sqlite, kill by PID instead"))
+		}
+		dur, err := time.ParseDuration(arg)
 		dio.Assert(stderr, err, "provided duration is not a valid Go time.Duration")
 		kill = slice.Filter(processes, func(p ddb.Process) bool {
 			return p.Duration > dur
 		})
 		break
 	case *fquery:
-		rgx, err := regexp.Compile(flag.Arg(0))
+		rgx, err := regexp.Compile(arg)
 		dio.Assert(stderr, err, "provided regex is not a valid Go regexp")
 		kill = slice.Filter(processes, func(p ddb.Process) bool {
 			return rgx.MatchString(p.Query)
@@ -93,16 +122,16 @@ func main() {
 		break
 	case *fuser:
 		kill = slice.Filter(processes, func(p ddb.Process) bool {
-			return p.Username == flag.Arg(0)
+			return p.Username == arg
 		})
 		break
 	case *fdb:
 		kill = slice.Filter(processes, func(p ddb.Process) bool {
-			return p.Database == flag.Arg(0)
+			return p.Database == arg
 		})
 		break
 	default:
-		pid, err := strconv.Atoi(flag.Arg(0))
+		pid, err := strconv.Atoi(arg)
 		dio.Assert(stderr, err, "provided PID is not a number")
 		kill = slice.Filter(processes, func(p ddb.Process) bool {
 			return p.Pid == pid
@@ -113,7 +142,7 @@ func main() {
 	// Kill the processes
 	statuses := map[int]error{}
 	for _, p := range kill {
-		statuses[p.Pid] = db.KillProcess(p.Pid, *fforce)
+		statuses[p.Pid] = db.KillProcessContext(ctx, p.Pid, *fforce)
 	}
 
 	// Report the status
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/yznts/dsh/pkg/dconf"
+	"github.com/yznts/dsh/pkg/ddb"
+	_ "github.com/yznts/dsh/pkg/dext"
+	"github.com/yznts/dsh/pkg/dio"
+	"go.kyoto.codes/zen/v3/slice"
+)
+
+// Tool flags
+var (
+	fdsn     = flag.String("dsn", "", "Database connection (can be set via DSN/DATABASE/DATABASE_URL env)")
+	fconnect = flag.Duration("connect-timeout", ddb.DefaultOpenTimeout, "Connection timeout, transient network errors are retried until it's exceeded")
+	ftimeout = flag.Duration("timeout", 0, "Statement timeout (e.g. 30s), Ctrl-C cancels the statement as well")
+	fcsv     = flag.Bool("csv", false, "Output in CSV format")
+	fjson    = flag.Bool("json", false, "Output in JSON format")
+	fjsonl   = flag.Bool("jsonl", false, "Output in JSON lines format")
+	fwaiting = flag.Bool("waiting", false, "List only awaited (not granted) locks")
+)
+
+// Tool usage / description
+var (
+	fusage = "[flags...] [file.db] [[schema.]table]"
+	fdescr = "The dlocks utility lists database locks with the processes holding or awaiting them. " +
+		"Awaited locks go first and are marked as WAITING. " +
+		"If table is provided, only locks on this table are listed."
+)
+
+// Database connection
+var db ddb.Database
+
+// Output writers
+var (
+	stdout dio.Writer
+	stderr dio.Writer
+)
+
+// Simplify assignments
+var err error
+
+// Tool arguments (without database file, if provided as the first one)
+var args []string
+
+func main() {
+	// Provide usage
+	flag.Usage = dio.Usage(fusage, fdescr)
+
+	// Parse flags
+	flag.Parse()
+
+	// Resolve output writer
+	stdout = dio.Open(os.Stdout, false, *fcsv, *fjson, *fjsonl)
+	stderr = dio.Open(os.Stderr, false, *fcsv, *fjson, *fjsonl)
+
+	// Database file might be provided as the first argument
+	*fdsn, args = dconf.FileArg(*fdsn, flag.Args())
+
+	// Resolve dsn and database connection
+	dsn, err := dconf.GetDsn(*fdsn)
+	dio.Assert(stderr, err)
+	connctx, conncancel := dio.Context(*fconnect)
+	db, err = ddb.OpenContext(connctx, dsn)
+	conncancel()
+	dio.Assert(stderr, err)
+	if db, iscloser := db.(io.Closer); iscloser {
+		defer db.Close()
+	}
+
+	// Resolve statement context,
+	// canceled on timeout or interrupt.
+	ctx, cancel := dio.Context(*ftimeout)
+	defer cancel()
+
+	// Parse table identifier, if provided
+	var table ddb.TableIdent
+	if len(args) > 0 {
+		table, err = ddb.ParseTableIdent(args[0])
+		dio.Assert(stderr, err)
+	}
+
+	// Query the database for the locks
+	locks, err := db.QueryLocksContext(ctx)
+	dio.Assert(stderr, err)
+
+	// Filter locks by table and status
+	locks = filter(locks, table, *fwaiting)
+
+	// Warn about awaited locks
+	waiting := filter(locks, ddb.TableIdent{}, true)
+	if len(waiting) > 0 {
+		if stdout, warner := stdout.(dio.WarningWriter); warner {
+			stdout.WriteWarning(fmt.Sprintf("%d lock(s) are awaited", len(waiting)))
+		}
+	}
+
+	// Write locks
+	stdout.WriteData(&ddb.Data{
+		Cols: []string{"PID", "STATUS", "TABLE", "TYPE", "MODE", "USERNAME", "DURATION", "QUERY"},
+		Rows: slice.Map(locks, func(l ddb.Lock) []any {
+			status := "granted"
+			if !l.Granted {
+				status = "WAITING"
+			}
+			// Not every lock is on a table
+			relation := ""
+			if l.Table != "" {
+				relation = l.Ident().String()
+			}
+			return []any{l.Pid, status, relation, l.Type, l.Mode, l.Username, l.Duration, l.Query}
+		}),
+	})
+}
+
+// filter returns the locks on the table (if provided),
+// only awaited ones if requested.
+// Table schema is optional, any schema matches if it's omitted.
+func filter(locks []ddb.Lock, table ddb.TableIdent, waiting bool) []ddb.Lock {
+	return slice.Filter(locks, func(l ddb.Lock) bool {
+		if table.Name != "" && (l.Table != table.Name || (table.Schema != "" && l.Schema != table.Schema)) {
+			return false
+		}
+		return !waiting || !l.Granted
+	})
+}
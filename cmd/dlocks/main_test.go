@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yznts/dsh/pkg/ddb"
+)
+
+func TestFilter(t *testing.T) {
+	locks := []ddb.Lock{
+		{Pid: 1, Schema: "app", Table: "users", Granted: true},
+		{Pid: 2, Schema: "app", Table: "users", Granted: false},
+		{Pid: 3, Schema: "log", Table: "users", Granted: false},
+		{Pid: 4, Schema: "app", Table: "orders", Granted: true},
+		{Pid: 5, Type: "transactionid", Granted: false},
+	}
+	tests := []struct {
+		name    string
+		table   ddb.TableIdent
+		waiting bool
+		pids    []int
+	}{
+		{"all", ddb.TableIdent{}, false, []int{1, 2, 3, 4, 5}},
+		{"waiting", ddb.TableIdent{}, true, []int{2, 3, 5}},
+		{"table in any schema", ddb.TableIdent{Name: "users"}, false, []int{1, 2, 3}},
+		{"qualified table", ddb.TableIdent{Schema: "app", Name: "users"}, false, []int{1, 2}},
+		{"waiting on qualified table", ddb.TableIdent{Schema: "app", Name: "users"}, true, []int{2}},
+		{"not locked table", ddb.TableIdent{Name: "items"}, false, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pids := []int{}
+			for _, l := range filter(locks, tt.table, tt.waiting) {
+				pids = append(pids, l.Pid)
+			}
+			if !reflect.DeepEqual(pids, tt.pids) {
+				t.Fatalf("filter() = %v, want %v", pids, tt.pids)
+			}
+		})
+	}
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/yznts/dsh/pkg/dconf"
 	"github.com/yznts/dsh/pkg/ddb"
+	_ "github.com/yznts/dsh/pkg/dext"
 	"github.com/yznts/dsh/pkg/dio"
 	"go.kyoto.codes/zen/v3/logic"
 	"go.kyoto.codes/zen/v3/slice"
@@ -16,19 +21,29 @@ import (
 
 // Tool flags
 var (
-	fdsn   = flag.String("dsn", "", "Database connection (can be set via DSN/DATABASE/DATABASE_URL env)")
-	fsys   = flag.Bool("sys", false, "List all tables (including system)")
-	fsql   = flag.Bool("sql", false, "Output in SQL format")
-	fcsv   = flag.Bool("csv", false, "Output in CSV format")
-	fjson  = flag.Bool("json", false, "Output in JSON format")
-	fjsonl = flag.Bool("jsonl", false, "Output in JSON lines format")
-	flong  = flag.Bool("long", false, "Output in long format (with additional information)")
+	fdsn     = flag.String("dsn", "", "Database connection (can be set via DSN/DATABASE/DATABASE_URL env)")
+	fconnect = flag.Duration("connect-timeout", ddb.DefaultOpenTimeout, "Connection timeout, transient network errors are retried until it's exceeded")
+	ftimeout = flag.Duration("timeout", 0, "Statement timeout (e.g. 30s), Ctrl-C cancels the statement as well")
+	fdbname  = flag.String("database", "", "Database to connect to, instead of the one in DSN (postgres and mysql)")
+	fschema  = flag.String("schema", "", "Default schema (search_path for postgres, database for mysql)")
+	fsys     = flag.Bool("sys", false, "List all tables (including system)")
+	fsql     = flag.Bool("sql", false, "Output in SQL format")
+	fcsv     = flag.Bool("csv", false, "Output in CSV format")
+	fjson    = flag.Bool("json", false, "Output in JSON format")
+	fjsonl   = flag.Bool("jsonl", false, "Output in JSON lines format")
+	flong    = flag.Bool("long", false, "Output in long format (with additional information)")
+	fsort    = flag.String("sort", "name", "Sort tables by name, size or rows (size and rows are descending)")
+	fkind    = flag.String("kind", "", "List only relations of the kind (table, view, materialized view, foreign table, sequence)")
+	findexes = flag.Bool("indexes", false, "List indexes instead of columns (for all tables, if no table provided)")
+	fcons    = flag.Bool("constraints", false, "List constraints instead of columns (for all tables, if no table provided)")
+	fdbs     = flag.Bool("databases", false, "List databases on the server instead of tables (use -database to descend into one)")
 )
 
 // Tool usage / description
 var (
-	fusage = "[flags...] [table]"
-	fdescr = "The dls utility lists tables/columns in the database."
+	fusage = "[flags...] [file.db] [[schema.]table]"
+	fdescr = "The dls utility lists tables/columns/indexes/constraints in the database. " +
+		"Table might be schema-qualified, names with dots or quotes must be quoted (e.g. \"App\".\"Users\")."
 )
 
 // Database connection
@@ -43,6 +58,9 @@ var (
 // Simplify assignments
 var err error
 
+// Tool arguments (without database file, if provided as the first one)
+var args []string
+
 func main() {
 	// Provide usage
 	flag.Usage = dio.Usage(fusage, fdescr)
@@ -54,51 +72,183 @@ func main() {
 	stdout = dio.Open(os.Stdout, *fsql, *fcsv, *fjson, *fjsonl)
 	stderr = dio.Open(os.Stderr, *fsql, *fcsv, *fjson, *fjsonl)
 
+	// Database file might be provided as the first argument
+	*fdsn, args = dconf.FileArg(*fdsn, flag.Args())
+
 	// Resolve dsn and database connection
 	dsn, err := dconf.GetDsn(*fdsn)
 	dio.Assert(stderr, err)
-	db, err = ddb.Open(dsn)
+	dsn, err = ddb.WithDatabase(dsn, *fdbname)
+	dio.Assert(stderr, err)
+	dsn, err = ddb.WithSchema(dsn, *fschema)
+	dio.Assert(stderr, err)
+	// Introspection doesn't modify the database,
+	// so file databases are opened read-only (unless mode is provided)
+	dsn, err = ddb.WithReadOnly(dsn)
+	dio.Assert(stderr, err)
+	connctx, conncancel := dio.Context(*fconnect)
+	db, err = ddb.OpenContext(connctx, dsn)
+	conncancel()
 	dio.Assert(stderr, err)
 	if db, iscloser := db.(io.Closer); iscloser {
 		defer db.Close()
 	}
 
+	// Resolve statement context,
+	// canceled on timeout or interrupt.
+	ctx, cancel := dio.Context(*ftimeout)
+	defer cancel()
+
 	// Validate flags compatibility
 	if *fsys && *fsql {
 		dio.Assert(stderr, errors.New("flag -sys is not compatible with -sql (export of system columns)"))
 	}
+	if *findexes && *fcons {
+		dio.Assert(stderr, errors.New("flag -indexes is not compatible with -constraints"))
+	}
+	if !slice.Contains([]string{"name", "size", "rows"}, *fsort) {
+		dio.Assert(stderr, fmt.Errorf("unknown sort %q", *fsort))
+	}
+
+	// Validate kind filter
+	kinds := []ddb.TableKind{
+		ddb.TableKindTable,
+		ddb.TableKindView,
+		ddb.TableKindMaterializedView,
+		ddb.TableKindForeignTable,
+		ddb.TableKindSequence,
+	}
+	if *fkind != "" && !slice.Contains(kinds, ddb.TableKind(*fkind)) {
+		dio.Assert(stderr, fmt.Errorf("unknown kind %q", *fkind))
+	}
+
+	// List server databases, if requested.
+	// It's a server level listing, so no table is expected.
+	if *fdbs {
+		if *fsql || len(args) > 0 {
+			dio.Assert(stderr, errors.New("flag -databases is not compatible with -sql and table argument"))
+		}
+		databases, err := db.QueryDatabasesContext(ctx)
+		dio.Assert(stderr, err)
+		// Sort databases (database order is kept for equal values)
+		if *fsort == "size" {
+			sort.SliceStable(databases, func(i, j int) bool {
+				return databases[i].Size > databases[j].Size
+			})
+		}
+		// Write databases
+		stdout.WriteData(&ddb.Data{
+			Cols: []string{"NAME", "OWNER", "ENCODING", "SIZE", "IS_CURRENT"},
+			Rows: slice.Map(databases, func(d ddb.DatabaseInfo) []any {
+				return []any{d.Name, d.Owner, d.Encoding, d.Size, d.IsCurrent}
+			}),
+		})
+		// Exit, we're done here
+		return
+	}
+
+	// Parse table identifier, if provided.
+	// Unqualified table is resolved within the default schema.
+	var table ddb.TableIdent
+	if len(args) > 0 {
+		table, err = ddb.ParseTableIdent(args[0])
+		dio.Assert(stderr, err)
+		if table.Schema == "" {
+			table.Schema = *fschema
+		}
+	}
 
 	// If writer is SQL, we have a separate processing for it.
 	if stdout, ok := stdout.(*dio.Sql); ok {
 		// Determine tables we want to extract.
-		// If no arguments, list all tables.
+		// If no arguments, list all tables (within the schema, if provided).
 		// Otherwise, use provided table name.
-		tables, err := db.QueryTables()
+		tables, err := db.QueryTablesContext(ctx)
 		dio.Assert(stderr, err)
-		if len(flag.Args()) > 0 {
+		if len(args) > 0 {
 			tables = slice.Filter(tables, func(t ddb.Table) bool {
-				return t.Name == flag.Arg(0)
+				return t.Name == table.Name && (table.Schema == "" || t.Schema == table.Schema)
+			})
+		} else if *fschema != "" {
+			tables = slice.Filter(tables, func(t ddb.Table) bool {
+				return t.Schema == *fschema
 			})
 		}
 
-		// Filter system tables
+		// Filter system tables and kind.
+		// Sequences and foreign tables are not exported,
+		// they're depending on the database-specific options.
 		tables = slice.Filter(tables, func(t ddb.Table) bool {
-			return !t.IsSystem
+			return !t.IsSystem &&
+				(*fkind == "" || t.Kind == ddb.TableKind(*fkind)) &&
+				(t.Kind == ddb.TableKindTable || t.Kind.IsView())
 		})
 
+		// Views are depending on tables,
+		// so we're writing them after all tables.
+		tables = append(
+			slice.Filter(tables, func(t ddb.Table) bool { return !t.Kind.IsView() }),
+			slice.Filter(tables, func(t ddb.Table) bool { return t.Kind.IsView() })...,
+		)
+
 		// Write schema for each table
 		for _, table := range tables {
-			// Get columns
-			columns, err := db.QueryColumns(table.Name)
+			// Constraints of the table (views have none)
+			var constraints []ddb.Constraint
+			// Set table name
+			stdout.SetTable(table.Ident().Quoted(db.QuoteIdent))
+			stdout.SetQuote(db.QuoteIdent)
+			// Write view definition instead of columns, if it's a view
+			if table.Kind.IsView() {
+				stdout.SetMode("view")
+				stdout.WriteData(&ddb.Data{
+					Cols: []string{"VIEW_KIND", "DEFINITION"},
+					Rows: [][]any{{table.Kind, table.Definition}},
+				})
+				// Regular views can't have indexes
+				if table.Kind == ddb.TableKindView {
+					continue
+				}
+			} else {
+				// Get columns and constraints
+				columns, err := db.QueryColumnsContext(ctx, table.Ident())
+				dio.Assert(stderr, err)
+				constraints, err = db.QueryConstraintsContext(ctx, table.Ident())
+				dio.Assert(stderr, err)
+				// Set mode and constraints
+				stdout.SetMode("schema")
+				stdout.SetConstraints(constraints)
+				// Write columns
+				stdout.WriteData(&ddb.Data{
+					Cols: []string{"COLUMN_NAME", "COLUMN_TYPE", "IS_PK", "IS_NL", "DEF"},
+					Rows: slice.Map(columns, func(c ddb.Column) []any {
+						return []any{c.Name, c.Type, c.IsPrimary, c.IsNullable, c.Default}
+					}),
+				})
+			}
+			// Get indexes.
+			// Indexes, backing the constraints, are going to be re-created by the constraints.
+			// SQLite auto-indexes are not named after the constraints,
+			// but they can't be created manually anyway.
+			indexes, err := db.QueryIndexesContext(ctx, table.Ident())
 			dio.Assert(stderr, err)
-			// Set mode and table name
-			stdout.SetMode("schema")
-			stdout.SetTable(table.Name)
-			// Write columns
+			indexes = slice.Filter(indexes, func(i ddb.Index) bool {
+				backing := slice.Filter(constraints, func(c ddb.Constraint) bool {
+					return c.Name == i.Name && (c.Type == ddb.ConstraintPrimaryKey || c.Type == ddb.ConstraintUnique)
+				})
+				return len(backing) == 0 && !strings.HasPrefix(i.Name, "sqlite_autoindex_")
+			})
+			// Write indexes.
+			// SQLite doesn't allow to qualify the indexed table (index is qualified instead),
+			// so we're omitting the default schema.
+			stdout.SetMode("index")
+			if table.Schema == "main" {
+				stdout.SetTable(db.QuoteIdent(table.Name))
+			}
 			stdout.WriteData(&ddb.Data{
-				Cols: []string{"COLUMN_NAME", "COLUMN_TYPE", "IS_PK", "IS_NL", "DEF", "FK"},
-				Rows: slice.Map(columns, func(c ddb.Column) []any {
-					return []any{c.Name, c.Type, c.IsPrimary, c.IsNullable, c.Default, c.ForeignRef}
+				Cols: []string{"INDEX_NAME", "COLUMNS", "IS_UNIQUE", "IS_PK", "METHOD", "PREDICATE", "INCLUDE", "OPTIONS"},
+				Rows: slice.Map(indexes, func(i ddb.Index) []any {
+					return []any{i.Name, i.Columns, i.IsUnique, i.IsPrimary, i.Method, i.Predicate, i.Include, i.Options}
 				}),
 			})
 		}
@@ -109,11 +259,89 @@ func main() {
 
 	// Otherwise, proceed with regular listing.
 
+	// If indexes are requested, list them instead of tables/columns.
+	// If no arguments, list indexes for all tables (within the schema, if provided).
+	if *findexes {
+		// Determine tables we want to inspect
+		tables := inspected(ctx, table)
+
+		// Collect indexes.
+		// Table columns are included only when listing multiple tables.
+		cols := []string{"INDEX_NAME", "COLUMNS", "INCLUDE", "IS_UNIQUE", "IS_PK", "METHOD", "PREDICATE"}
+		if len(args) == 0 {
+			cols = append([]string{"TABLE_SCHEMA", "TABLE_NAME"}, cols...)
+		}
+		rows := [][]any{}
+		for _, t := range tables {
+			indexes, err := db.QueryIndexesContext(ctx, t)
+			dio.Assert(stderr, err)
+			for _, i := range indexes {
+				// Key columns are listed along with their options (e.g. DESC)
+				keys := make([]string, len(i.Columns))
+				for n, c := range i.Columns {
+					keys[n] = c
+					if n < len(i.Options) && i.Options[n] != "" {
+						keys[n] += " " + i.Options[n]
+					}
+				}
+				row := []any{i.Name, strings.Join(keys, ", "), strings.Join(i.Include, ", "), i.IsUnique, i.IsPrimary, i.Method, i.Predicate}
+				if len(args) == 0 {
+					row = append([]any{logic.Or(t.Schema, "N/A"), t.Name}, row...)
+				}
+				rows = append(rows, row)
+			}
+		}
+
+		// Write indexes
+		stdout.WriteData(&ddb.Data{
+			Cols: cols,
+			Rows: rows,
+		})
+
+		// Exit, we're done here
+		return
+	}
+
+	// If constraints are requested, list them instead of tables/columns.
+	// If no arguments, list constraints for all tables (within the schema, if provided).
+	if *fcons {
+		// Determine tables we want to inspect
+		tables := inspected(ctx, table)
+
+		// Collect constraints.
+		// Table columns are included only when listing multiple tables.
+		cols := []string{"CONSTRAINT_NAME", "CONSTRAINT_TYPE", "COLUMNS", "REFERENCES", "ON_UPDATE", "ON_DELETE", "CHECK"}
+		if len(args) == 0 {
+			cols = append([]string{"TABLE_SCHEMA", "TABLE_NAME"}, cols...)
+		}
+		rows := [][]any{}
+		for _, t := range tables {
+			constraints, err := db.QueryConstraintsContext(ctx, t)
+			dio.Assert(stderr, err)
+			for _, c := range constraints {
+				row := []any{c.Name, string(c.Type), strings.Join(c.Columns, ", "), reference(c), c.OnUpdate, c.OnDelete, c.Check}
+				if len(args) == 0 {
+					row = append([]any{logic.Or(t.Schema, "N/A"), t.Name}, row...)
+				}
+				rows = append(rows, row)
+			}
+		}
+
+		// Write constraints
+		stdout.WriteData(&ddb.Data{
+			Cols: cols,
+			Rows: rows,
+		})
+
+		// Exit, we're done here
+		return
+	}
+
 	// If no arguments, list tables.
 	// Otherwise, list columns for provided table name.
-	if len(flag.Args()) == 0 {
+	if len(args) == 0 {
 		// Get database tables
-		tables, err := db.QueryTables()
+		tables, err := db.QueryTablesContext(ctx)
 		dio.Assert(stderr, err)
 
 		// Filter system tables
@@ -123,24 +351,73 @@ func main() {
 			})
 		}
 
-		// If no schema, print 'N/A'
-		if slice.All(tables, func(t ddb.Table) bool { return t.Schema == "" }) {
-			tables = slice.Map(tables, func(t ddb.Table) ddb.Table {
-				t.Schema = "N/A"
-				return t
+		// Filter by schema, if provided
+		if *fschema != "" {
+			tables = slice.Filter(tables, func(t ddb.Table) bool {
+				return t.Schema == *fschema
 			})
 		}
 
+		// Filter by kind, if provided
+		if *fkind != "" {
+			tables = slice.Filter(tables, func(t ddb.Table) bool {
+				return t.Kind == ddb.TableKind(*fkind)
+			})
+		}
+
+		// Get tables statistics, if needed for output or sorting.
+		// Relations without statistics (views, sequences) are left empty.
+		stats := map[ddb.TableIdent]ddb.TableStats{}
+		if *flong || *fsort != "name" {
+			list, err := db.QueryTableStatsContext(ctx)
+			dio.Assert(stderr, err)
+			for _, s := range list {
+				stats[s.Ident()] = s
+			}
+		}
+
+		// Sort tables.
+		// Database order is kept for equal values.
+		sort.SliceStable(tables, func(i, j int) bool {
+			si, sj := stats[tables[i].Ident()], stats[tables[j].Ident()]
+			switch *fsort {
+			case "size":
+				return si.TotalSize > sj.TotalSize
+			case "rows":
+				return si.Rows > sj.Rows
+			default:
+				return tables[i].Ident().String() < tables[j].Ident().String()
+			}
+		})
+
+		// Compose rows.
+		// If -long is set, include tables statistics.
+		cols := []string{"TABLE_SCHEMA", "TABLE_NAME", "TABLE_KIND", "IS_SYSTEM"}
+		if *flong {
+			cols = append(cols, "ROWS_EST", "TOTAL_SIZE", "INDEX_SIZE", "LAST_ANALYZE", "LAST_VACUUM")
+		}
+		noschema := slice.All(tables, func(t ddb.Table) bool { return t.Schema == "" })
+		rows := slice.Map(tables, func(t ddb.Table) []any {
+			// If no schema, print 'N/A'
+			row := []any{logic.Tr(noschema, "N/A", t.Schema), t.Name, string(t.Kind), t.IsSystem}
+			if !*flong {
+				return row
+			}
+			stat, ok := stats[t.Ident()]
+			if !ok {
+				return append(row, nil, nil, nil, nil, nil)
+			}
+			return append(row, stat.Rows, stat.TotalSize, stat.IndexSize, timestamp(stat.LastAnalyze), timestamp(stat.LastVacuum))
+		})
+
 		// Write tables
 		stdout.WriteData(&ddb.Data{
-			Cols: []string{"TABLE_SCHEMA", "TABLE_NAME", "IS_SYSTEM"},
-			Rows: slice.Map(tables, func(t ddb.Table) []any {
-				return []any{t.Schema, t.Name, t.IsSystem}
-			}),
+			Cols: cols,
+			Rows: rows,
 		})
 	} else {
 		// Get database columns
-		columns, err := db.QueryColumns(flag.Arg(0))
+		columns, err := db.QueryColumnsContext(ctx, table)
 		dio.Assert(stderr, err)
 
 		// Switch behavior based on -long flag.
@@ -151,6 +428,12 @@ func main() {
 			rows = [][]any{}
 		)
 		if *flong {
+			// Get foreign keys, as a column might be a part of multiple ones
+			constraints, err := db.QueryConstraintsContext(ctx, table)
+			dio.Assert(stderr, err)
+			fks := slice.Filter(constraints, func(c ddb.Constraint) bool {
+				return c.Type == ddb.ConstraintForeignKey
+			})
 			cols = []string{
 				"COLUMN_NAME",
 				"COLUMN_TYPE",
@@ -166,10 +449,12 @@ func main() {
 					c.IsPrimary,
 					c.IsNullable,
 					c.Default,
-					logic.Tr(c.ForeignRef != "",
-						fmt.Sprintf("%s upd(%s) del(%s)", c.ForeignRef, c.ForeignOnUpdate, c.ForeignOnDelete),
-						"",
-					),
+					strings.Join(slice.Map(
+						slice.Filter(fks, func(fk ddb.Constraint) bool { return slice.Contains(fk.Columns, c.Name) }),
+						func(fk ddb.Constraint) string {
+							return fmt.Sprintf("%s upd(%s) del(%s)", reference(fk), fk.OnUpdate, fk.OnDelete)
+						},
+					), "; "),
 				}
 			})
 		} else {
@@ -192,3 +477,43 @@ func main() {
 		})
 	}
 }
+
+// inspected returns the tables to inspect with -indexes/-constraints.
+// If table is not provided, all tables are returned
+// (filtered with -sys, -schema and -kind flags).
+func inspected(ctx context.Context, table ddb.TableIdent) []ddb.TableIdent {
+	if len(args) > 0 {
+		return []ddb.TableIdent{table}
+	}
+	tables, err := db.QueryTablesContext(ctx)
+	dio.Assert(stderr, err)
+	tables = slice.Filter(tables, func(t ddb.Table) bool {
+		return (*fsys || !t.IsSystem) &&
+			(*fschema == "" || t.Schema == *fschema) &&
+			(*fkind == "" || t.Kind == ddb.TableKind(*fkind))
+	})
+	return slice.Map(tables, func(t ddb.Table) ddb.TableIdent {
+		return t.Ident()
+	})
+}
+
+// reference formats the foreign key reference, like `table(col1, col2)`.
+// Referenced columns are omitted, if the key references the primary key implicitly.
+func reference(c ddb.Constraint) string {
+	if c.Type != ddb.ConstraintForeignKey {
+		return ""
+	}
+	if len(c.ForeignColumns) == 0 {
+		return c.ForeignTable.String()
+	}
+	return fmt.Sprintf("%s(%s)", c.ForeignTable, strings.Join(c.ForeignColumns, ", "))
+}
+
+// timestamp returns nil for zero time,
+// so unknown timestamps are written as empty values.
+func timestamp(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
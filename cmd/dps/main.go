@@ -4,25 +4,34 @@ import (
 	"flag"
 	"io"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/yznts/dsh/pkg/dconf"
 	"github.com/yznts/dsh/pkg/ddb"
+	_ "github.com/yznts/dsh/pkg/dext"
 	"github.com/yznts/dsh/pkg/dio"
 	"go.kyoto.codes/zen/v3/slice"
 )
 
 // Tool flags
 var (
-	fdsn   = flag.String("dsn", "", "Database connection (can be set via DSN/DATABASE/DATABASE_URL env)")
-	fcsv   = flag.Bool("csv", false, "Output in CSV format")
-	fjson  = flag.Bool("json", false, "Output in JSON format")
-	fjsonl = flag.Bool("jsonl", false, "Output in JSON lines format")
+	fdsn     = flag.String("dsn", "", "Database connection (can be set via DSN/DATABASE/DATABASE_URL env)")
+	fconnect = flag.Duration("connect-timeout", ddb.DefaultOpenTimeout, "Connection timeout, transient network errors are retried until it's exceeded")
+	ftimeout = flag.Duration("timeout", 0, "Statement timeout (e.g. 30s), Ctrl-C cancels the statement as well")
+	fcsv     = flag.Bool("csv", false, "Output in CSV format")
+	fjson    = flag.Bool("json", false, "Output in JSON format")
+	fjsonl   = flag.Bool("jsonl", false, "Output in JSON lines format")
+	flong    = flag.Bool("long", false, "Output in long format (with client address, application name and transaction start)")
+	ftree    = flag.Bool("tree", false, "Output blocking chains only, blocked processes are nested under the blockers")
 )
 
 // Tool usage / description
 var (
-	fusage = "[flags...]"
-	fdescr = "The dps utility outputs list of database processes."
+	fusage = "[flags...] [file.db]"
+	fdescr = "The dps utility outputs list of database processes. " +
+		"Each process is reported with its state, wait event and PIDs of the processes blocking it (if supported by the database)."
 )
 
 // Database connection
@@ -37,6 +46,9 @@ var (
 // Simplify assignments
 var err error
 
+// Tool arguments (without database file, if provided as the first one)
+var args []string
+
 func main() {
 	// Provide usage
 	flag.Usage = dio.Usage(fusage, fdescr)
@@ -45,27 +57,129 @@ func main() {
 	flag.Parse()
 
 	// Resolve output writer
-	stdout = dio.Open(os.Stdout, *fcsv, *fjson, *fjsonl)
-	stderr = dio.Open(os.Stderr, *fcsv, *fjson, *fjsonl)
+	stdout = dio.Open(os.Stdout, false, *fcsv, *fjson, *fjsonl)
+	stderr = dio.Open(os.Stderr, false, *fcsv, *fjson, *fjsonl)
+
+	// Database file might be provided as the first argument
+	*fdsn, args = dconf.FileArg(*fdsn, flag.Args())
 
 	// Resolve dsn and database connection
 	dsn, err := dconf.GetDsn(*fdsn)
 	dio.Assert(stderr, err)
-	db, err = ddb.Open(dsn)
+	connctx, conncancel := dio.Context(*fconnect)
+	db, err = ddb.OpenContext(connctx, dsn)
+	conncancel()
 	dio.Assert(stderr, err)
 	if db, iscloser := db.(io.Closer); iscloser {
 		defer db.Close()
 	}
 
+	// Resolve statement context,
+	// canceled on timeout or interrupt.
+	ctx, cancel := dio.Context(*ftimeout)
+	defer cancel()
+
 	// Query the database for the currently running processes
-	processes, err := db.QueryProcesses()
+	processes, err := db.QueryProcessesContext(ctx)
 	dio.Assert(stderr, err)
 
+	// Resolve processes to output, with nesting levels
+	levels := slice.Map(processes, func(ddb.Process) int { return 0 })
+	if *ftree {
+		processes, levels = tree(processes)
+	}
+
+	// Compose columns
+	cols := []string{"PID", "STATE", "DURATION", "USERNAME", "DATABASE"}
+	if *flong {
+		cols = append(cols, "CLIENT", "APPLICATION", "XACT_START")
+	}
+	cols = append(cols, "WAIT_EVENT", "BLOCKED_BY", "QUERY")
+
 	// Write processes
+	rows := [][]any{}
+	for i, p := range processes {
+		// Nested processes are indented in the tree mode
+		pid := any(p.Pid)
+		if *ftree {
+			pid = strings.Repeat("  ", levels[i]) + strconv.Itoa(p.Pid)
+		}
+		row := []any{pid, p.State, p.Duration, p.Username, p.Database}
+		if *flong {
+			xactstart := any(nil)
+			if !p.XactStart.IsZero() {
+				xactstart = p.XactStart
+			}
+			row = append(row, p.Client, p.Application, xactstart)
+		}
+		blockedby := strings.Join(slice.Map(p.BlockedBy, strconv.Itoa), ",")
+		row = append(row, p.WaitEvent, blockedby, p.Query)
+		rows = append(rows, row)
+	}
 	stdout.WriteData(&ddb.Data{
-		Cols: []string{"PID", "DURATION", "USERNAME", "DATABASE", "QUERY"},
-		Rows: slice.Map(processes, func(p ddb.Process) []any {
-			return []any{p.Pid, p.Duration, p.Username, p.Database, p.Query}
-		}),
+		Cols: cols,
+		Rows: rows,
 	})
 }
+
+// tree orders processes into blocking chains.
+// Each blocker is followed by the processes it blocks, with increased nesting level.
+// Processes, not involved in any blocking, are omitted.
+func tree(processes []ddb.Process) ([]ddb.Process, []int) {
+	// Map blockers to the blocked processes
+	byPid := map[int]ddb.Process{}
+	blocks := map[int][]int{}
+	for _, p := range processes {
+		byPid[p.Pid] = p
+		for _, blocker := range p.BlockedBy {
+			blocks[blocker] = append(blocks[blocker], p.Pid)
+		}
+	}
+	// Walk the chains, starting from the given process.
+	// Visited processes are tracked along the path,
+	// so deadlocks (cycles) don't lead to the infinite recursion.
+	result, levels := []ddb.Process{}, []int{}
+	visited := map[int]bool{}
+	var walk func(pid int, level int, path map[int]bool)
+	walk = func(pid int, level int, path map[int]bool) {
+		p, ok := byPid[pid]
+		if !ok {
+			// Blocker might be already gone
+			p = ddb.Process{Pid: pid}
+		}
+		result, levels = append(result, p), append(levels, level)
+		visited[pid], path[pid] = true, true
+		defer delete(path, pid)
+		for _, blocked := range blocks[pid] {
+			if !path[blocked] {
+				walk(blocked, level+1, path)
+			}
+		}
+	}
+	// Start with the root blockers (not blocked by anyone)
+	for _, p := range processes {
+		if len(p.BlockedBy) == 0 && len(blocks[p.Pid]) > 0 {
+			walk(p.Pid, 0, map[int]bool{})
+		}
+	}
+	// Blockers, missing in the process list
+	missing := []int{}
+	for pid := range blocks {
+		if _, ok := byPid[pid]; !ok {
+			missing = append(missing, pid)
+		}
+	}
+	sort.Ints(missing)
+	for _, pid := range missing {
+		if !visited[pid] {
+			walk(pid, 0, map[int]bool{})
+		}
+	}
+	// Remaining blocked processes are in the deadlock cycles
+	for _, p := range processes {
+		if len(p.BlockedBy) > 0 && !visited[p.Pid] {
+			walk(p.Pid, 0, map[int]bool{})
+		}
+	}
+	return result, levels
+}
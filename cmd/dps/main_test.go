@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yznts/dsh/pkg/ddb"
+)
+
+func TestTree(t *testing.T) {
+	tests := []struct {
+		name      string
+		processes []ddb.Process
+		pids      []int
+		levels    []int
+	}{
+		{
+			"no blocking",
+			[]ddb.Process{{Pid: 1}, {Pid: 2}},
+			[]int{},
+			[]int{},
+		},
+		{
+			"chain",
+			[]ddb.Process{{Pid: 3, BlockedBy: []int{2}}, {Pid: 1}, {Pid: 2, BlockedBy: []int{1}}, {Pid: 4}},
+			[]int{1, 2, 3},
+			[]int{0, 1, 2},
+		},
+		{
+			"multiple blocked",
+			[]ddb.Process{{Pid: 1}, {Pid: 2, BlockedBy: []int{1}}, {Pid: 3, BlockedBy: []int{1}}},
+			[]int{1, 2, 3},
+			[]int{0, 1, 1},
+		},
+		{
+			"missing blocker",
+			[]ddb.Process{{Pid: 2, BlockedBy: []int{9}}},
+			[]int{9, 2},
+			[]int{0, 1},
+		},
+		{
+			"deadlock",
+			[]ddb.Process{{Pid: 1, BlockedBy: []int{2}}, {Pid: 2, BlockedBy: []int{1}}},
+			[]int{1, 2},
+			[]int{0, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processes, levels := tree(tt.processes)
+			pids := []int{}
+			for _, p := range processes {
+				pids = append(pids, p.Pid)
+			}
+			if !reflect.DeepEqual(pids, tt.pids) || !reflect.DeepEqual(levels, tt.levels) {
+				t.Fatalf("tree() = %v %v, want %v %v", pids, levels, tt.pids, tt.levels)
+			}
+		})
+	}
+}
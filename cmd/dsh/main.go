@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/yznts/dsh/pkg/ddb"
+	_ "github.com/yznts/dsh/pkg/dext"
+	"github.com/yznts/dsh/pkg/dio"
+	"go.kyoto.codes/zen/v3/slice"
+)
+
+// Tool flags
+var (
+	fcsv   = flag.Bool("csv", false, "Output in CSV format")
+	fjson  = flag.Bool("json", false, "Output in JSON format")
+	fjsonl = flag.Bool("jsonl", false, "Output in JSON lines format")
+)
+
+// Tool usage / description
+var (
+	fusage = "[flags...] <command>"
+	fdescr = "The dsh utility provides information about the tools set itself. \n\n" +
+		"Commands: \n" +
+		"drivers - lists DSN schemes of compiled-in database drivers."
+)
+
+// Output writers
+var (
+	stdout dio.Writer
+	stderr dio.Writer
+)
+
+func main() {
+	// Provide usage
+	flag.Usage = dio.Usage(fusage, fdescr)
+
+	// Parse flags
+	flag.Parse()
+
+	// Resolve output writer
+	stdout = dio.Open(os.Stdout, false, *fcsv, *fjson, *fjsonl)
+	stderr = dio.Open(os.Stderr, false, *fcsv, *fjson, *fjsonl)
+
+	// Run the command
+	switch flag.Arg(0) {
+	case "drivers":
+		stdout.WriteData(&ddb.Data{
+			Cols: []string{"SCHEME"},
+			Rows: slice.Map(ddb.Drivers(), func(s string) []any {
+				return []any{s}
+			}),
+		})
+	case "":
+		flag.Usage()
+		os.Exit(1)
+	default:
+		dio.Assert(stderr, fmt.Errorf("unknown command: %s", flag.Arg(0)))
+	}
+}
@@ -1,30 +1,51 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"io"
 	"os"
 	"strings"
 
 	"github.com/yznts/dsh/pkg/dconf"
 	"github.com/yznts/dsh/pkg/ddb"
+	_ "github.com/yznts/dsh/pkg/dext"
 	"github.com/yznts/dsh/pkg/dio"
 )
 
 // Tool flags
 var (
-	fdsn   = flag.String("dsn", "", "Database connection (can be set via DSN/DATABASE/DATABASE_URL env)")
-	fcsv   = flag.Bool("csv", false, "Output in CSV format")
-	fjson  = flag.Bool("json", false, "Output in JSON format")
-	fjsonl = flag.Bool("jsonl", false, "Output in JSON lines format")
+	fdsn      = flag.String("dsn", "", "Database connection (can be set via DSN/DATABASE/DATABASE_URL env)")
+	fconnect  = flag.Duration("connect-timeout", ddb.DefaultOpenTimeout, "Connection timeout, transient network errors are retried until it's exceeded")
+	ftimeout  = flag.Duration("timeout", 0, "Statement timeout (e.g. 30s), Ctrl-C cancels the statement as well")
+	fdbname   = flag.String("database", "", "Database to connect to, instead of the one in DSN (postgres and mysql)")
+	fschema   = flag.String("schema", "", "Default schema (search_path for postgres, database for mysql)")
+	fcsv      = flag.Bool("csv", false, "Output in CSV format")
+	fjson     = flag.Bool("json", false, "Output in JSON format")
+	fjsonl    = flag.Bool("jsonl", false, "Output in JSON lines format")
+	ftx       = flag.Bool("tx", false, "Run the whole script in a single transaction, rolled back on the first error")
+	fdryrun   = flag.Bool("dry-run", false, "Run the script in a transaction and always roll it back (implies -tx)")
+	fcontinue = flag.Bool("continue-on-error", false, "Continue with the next statement on error (not compatible with -tx)")
+	fargs     = dio.StringsFlag("arg", "Query argument, bound to the placeholder ($1 for postgres, ? for others), repeat for multiple")
 )
 
 // Tool usage / description
 var (
-	fusage = "[flags...] sql"
+	fusage = "[flags...] [file.db] sql"
 	fdescr = "The dsql utility executes SQL query and writes the result to the standard output in desired format. " +
 		"It designed to be simple, therefore edge cases handling isn't included, like trying to query large tables in a formatted way. \n\n" +
-		"The query can be provided as argument or piped from another command (STDIN). "
+		"The query can be provided as argument or piped from another command (STDIN). " +
+		"Values can be bound to the query placeholders with -arg flags, in order of appearance (single statement only). \n\n" +
+		"Scripts with multiple statements are split according to the database dialect and executed one by one, " +
+		"with a separate result per statement. Execution stops on the first error, unless -continue-on-error is set. \n\n" +
+		"Statements returning multiple result sets (like stored procedures) are rendered set by set: " +
+		"a table per set (CSV tables are separated by a blank line), a JSON array of sets (for stored procedure calls, like CALL or EXEC), or JSON lines tagged with the set index (\"_set\", starting from the second set). \n\n" +
+		"Statements without rows (like INSERT, UPDATE or CREATE TABLE) are reported with affected rows count and last insert id (if supported). \n\n" +
+		"With -tx, the script runs in a single transaction, committed only if everything succeeds. " +
+		"With -dry-run, the transaction is always rolled back, so the changes can be checked safely " +
+		"(note that some statements, like DDL in MySQL, are committed implicitly)."
 )
 
 // Database connection
@@ -39,6 +60,9 @@ var (
 // Simplify assignments
 var err error
 
+// Tool arguments (without database file, if provided as the first one)
+var args []string
+
 func main() {
 	// Provide usage
 	flag.Usage = dio.Usage(fusage, fdescr)
@@ -50,17 +74,26 @@ func main() {
 	stdout = dio.Open(os.Stdout, false, *fcsv, *fjson, *fjsonl)
 	stderr = dio.Open(os.Stderr, false, *fcsv, *fjson, *fjsonl)
 
+	// Database file might be provided as the first argument
+	*fdsn, args = dconf.FileArg(*fdsn, flag.Args())
+
 	// Resolve dsn and database connection
 	dsn, err := dconf.GetDsn(*fdsn)
 	dio.Assert(stderr, err)
-	db, err = ddb.Open(dsn)
+	dsn, err = ddb.WithDatabase(dsn, *fdbname)
+	dio.Assert(stderr, err)
+	dsn, err = ddb.WithSchema(dsn, *fschema)
+	dio.Assert(stderr, err)
+	connctx, conncancel := dio.Context(*fconnect)
+	db, err = ddb.OpenContext(connctx, dsn)
+	conncancel()
 	dio.Assert(stderr, err)
 	if db, iscloser := db.(io.Closer); iscloser {
 		defer db.Close()
 	}
 
 	// Extract sql query from arguments
-	query := strings.Join(flag.Args(), " ")
+	query := strings.Join(args, " ")
 	// If no query provided, read from STDIN
 	if query == "" {
 		querybts, err := io.ReadAll(os.Stdin)
@@ -68,10 +101,122 @@ func main() {
 		query = string(querybts)
 	}
 
-	// Execute the query
-	data, err := db.QueryData(query)
+	// Resolve statement context,
+	// canceled on timeout or interrupt.
+	// It's resolved after the query is read,
+	// so Ctrl-C is not swallowed while waiting for STDIN
+	// and timeout doesn't include the input time.
+	ctx, cancel := dio.Context(*ftimeout)
+	defer cancel()
+
+	// Split the script into statements
+	stmts := db.SplitStatements(query)
+	if len(stmts) == 0 {
+		dio.Assert(stderr, errors.New("no statements to execute"))
+	}
+	if len(stmts) > 1 && len(fargs.Any()) > 0 {
+		dio.Assert(stderr, errors.New("query arguments are not supported for multiple statements"))
+	}
+
+	// Validate flags compatibility
+	tx := *ftx || *fdryrun
+	if tx && *fcontinue {
+		dio.Assert(stderr, errors.New("flag -continue-on-error is not compatible with -tx and -dry-run"))
+	}
+
+	// Start a transaction, if requested
+	if tx {
+		err = db.BeginContext(ctx)
+		dio.Assert(stderr, err)
+	}
+
+	// Multiple results must be written as a set
+	// (e.g. JSON array instead of a single object),
+	// errors as well if we're continuing on them.
+	stdoutsets, _ := stdout.(dio.SetsWriter)
+	stderrsets, _ := stderr.(dio.SetsWriter)
+	if len(stmts) == 1 {
+		stdoutsets = nil
+	}
+	if len(stmts) == 1 || !*fcontinue {
+		stderrsets = nil
+	}
+	if stdoutsets != nil {
+		stdoutsets.BeginSets()
+	}
+	if stderrsets != nil {
+		stderrsets.BeginSets()
+	}
+
+	// Run the statements one by one.
+	// Failed statement is referenced by its number, if there are many.
+	failed := false
+	for i, stmt := range stmts {
+		if err = run(ctx, stmt); err == nil {
+			continue
+		}
+		if len(stmts) > 1 {
+			err = fmt.Errorf("statement %d: %w", i+1, err)
+		}
+		if !*fcontinue {
+			break
+		}
+		stderr.WriteError(err)
+		failed, err = true, nil
+	}
+
+	// Finalize the transaction.
+	// It's rolled back on error or dry run, committed otherwise.
+	// Script error takes precedence over rollback one.
+	if tx {
+		if err != nil || *fdryrun {
+			if rberr := db.Rollback(); err == nil {
+				err = rberr
+			}
+		} else {
+			err = db.Commit()
+		}
+	}
+
+	// Close the sets and exit.
+	// Errors set is used only when continuing on errors,
+	// so there is no error to assert in that case.
+	if stdoutsets != nil {
+		stdoutsets.EndSets()
+	}
 	dio.Assert(stderr, err)
+	if stderrsets != nil {
+		stderrsets.EndSets()
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// run executes the statement and writes the result.
+func run(ctx context.Context, query string) error {
+	// Statements without rows (DML/DDL) are going through the exec path,
+	// so we can report execution summary instead of an empty table.
+	if !ddb.IsQuery(query) {
+		result, err := db.ExecuteContext(ctx, query, fargs.Any()...)
+		if err != nil {
+			return err
+		}
+		stdout.WriteResult(result)
+		return nil
+	}
 
-	// Write the result
-	stdout.WriteData(data)
+	// Execute the query
+	stream, err := db.QueryStreamContext(ctx, query, fargs.Any()...)
+	if err != nil {
+		return err
+	}
+
+	// Write the result as it arrives.
+	// Writers choosing the output shape upfront (JSON)
+	// must know if the statement may return multiple result sets.
+	if w, ok := stdout.(dio.MultiSetWriter); ok {
+		w.ExpectSets(ddb.IsCall(query))
+	}
+	return stdout.WriteStream(stream)
 }
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/yznts/dsh/pkg/dconf"
+	"github.com/yznts/dsh/pkg/ddb"
+	_ "github.com/yznts/dsh/pkg/dext"
+	"github.com/yznts/dsh/pkg/dio"
+	"go.kyoto.codes/zen/v3/slice"
+)
+
+// Tool flags
+var (
+	fdsn     = flag.String("dsn", "", "Database connection (can be set via DSN/DATABASE/DATABASE_URL env)")
+	fconnect = flag.Duration("connect-timeout", ddb.DefaultOpenTimeout, "Connection timeout, transient network errors are retried until it's exceeded")
+	ftimeout = flag.Duration("timeout", 0, "Statement timeout (e.g. 30s), Ctrl-C cancels the statement as well")
+	fcsv     = flag.Bool("csv", false, "Output in CSV format")
+	fjson    = flag.Bool("json", false, "Output in JSON format")
+	fjsonl   = flag.Bool("jsonl", false, "Output in JSON lines format")
+	frole    = flag.String("role", "", "Show effective privileges of the role (user@host for mysql)")
+	ftable   = flag.String("table", "", "Show effective privileges on the table ([schema.]table)")
+)
+
+// Tool usage / description
+var (
+	fusage = "[flags...] [file.db]"
+	fdescr = "The dusers utility lists database roles (users) and their memberships. " +
+		"If role or table is provided, it shows effective privileges instead, " +
+		"including the ones inherited from the granted roles (VIA column, except for NOINHERIT roles in postgres) " +
+		"and global, database or schema level privileges. " +
+		"With a table, only privileges applying to the table are shown: granted on it, or on the global and schema (database) levels for mysql."
+)
+
+// Database connection
+var db ddb.Database
+
+// Output writers
+var (
+	stdout dio.Writer
+	stderr dio.Writer
+)
+
+// Simplify assignments
+var err error
+
+// Tool arguments (without database file, if provided as the first one)
+var args []string
+
+func main() {
+	// Provide usage
+	flag.Usage = dio.Usage(fusage, fdescr)
+
+	// Parse flags
+	flag.Parse()
+
+	// Resolve output writer
+	stdout = dio.Open(os.Stdout, false, *fcsv, *fjson, *fjsonl)
+	stderr = dio.Open(os.Stderr, false, *fcsv, *fjson, *fjsonl)
+
+	// Database file might be provided as the first argument
+	*fdsn, args = dconf.FileArg(*fdsn, flag.Args())
+
+	// Resolve dsn and database connection
+	dsn, err := dconf.GetDsn(*fdsn)
+	dio.Assert(stderr, err)
+	connctx, conncancel := dio.Context(*fconnect)
+	db, err = ddb.OpenContext(connctx, dsn)
+	conncancel()
+	dio.Assert(stderr, err)
+	if db, iscloser := db.(io.Closer); iscloser {
+		defer db.Close()
+	}
+
+	// Resolve statement context,
+	// canceled on timeout or interrupt.
+	ctx, cancel := dio.Context(*ftimeout)
+	defer cancel()
+
+	// Query the database for the roles
+	roles, err := db.QueryRolesContext(ctx)
+	dio.Assert(stderr, err)
+
+	// Without role or table, just list the roles
+	if *frole == "" && *ftable == "" {
+		stdout.WriteData(&ddb.Data{
+			Cols: []string{"NAME", "CAN_LOGIN", "SUPERUSER", "INHERIT", "MEMBER_OF"},
+			Rows: slice.Map(roles, func(r ddb.Role) []any {
+				return []any{r.Name, r.CanLogin, r.Superuser, r.Inherit, strings.Join(r.MemberOf, ",")}
+			}),
+		})
+		return
+	}
+
+	// Parse table identifier, if provided
+	var table ddb.TableIdent
+	if *ftable != "" {
+		table, err = ddb.ParseTableIdent(*ftable)
+		dio.Assert(stderr, err)
+	}
+
+	// Query the database for the grants
+	grants, err := db.QueryGrantsContext(ctx)
+	dio.Assert(stderr, err)
+
+	// Resolve roles to check.
+	// Memberships are resolved against all roles.
+	byname := map[string]ddb.Role{}
+	for _, r := range roles {
+		byname[r.Name] = r
+	}
+	if *frole != "" {
+		role, ok := byname[*frole]
+		if !ok {
+			dio.Assert(stderr, fmt.Errorf("role %q not found", *frole))
+		}
+		roles = []ddb.Role{role}
+	}
+
+	// Compose effective privileges for each role
+	rows := [][]any{}
+	for _, role := range roles {
+		inherited := memberships(role.Name, byname)
+		for _, g := range grants {
+			if !inherited[g.Grantee] {
+				continue
+			}
+			if *ftable != "" && !applies(g, table) {
+				continue
+			}
+			// Privileges, granted directly, have no VIA
+			via := ""
+			if g.Grantee != role.Name {
+				via = g.Grantee
+			}
+			rows = append(rows, []any{role.Name, string(g.Level), g.Schema, g.Table, g.Privilege, g.Grantable, via})
+		}
+	}
+
+	// Write privileges
+	stdout.WriteData(&ddb.Data{
+		Cols: []string{"ROLE", "LEVEL", "SCHEMA", "TABLE", "PRIVILEGE", "GRANTABLE", "VIA"},
+		Rows: rows,
+	})
+}
+
+// memberships resolves the role itself and all roles it's a member of (transitively),
+// including PUBLIC pseudo-role, which is granted to everyone.
+// The chain stops at the roles without inheritance (postgres NOINHERIT),
+// their memberships are not inherited.
+func memberships(name string, roles map[string]ddb.Role) map[string]bool {
+	inherited := map[string]bool{"PUBLIC": true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		name, queue = queue[0], queue[1:]
+		// Membership graph might have cycles
+		if inherited[name] {
+			continue
+		}
+		inherited[name] = true
+		if roles[name].Inherit {
+			queue = append(queue, roles[name].MemberOf...)
+		}
+	}
+	return inherited
+}
+
+// applies checks if the grant applies to the table.
+// Global and schema level grants apply to all tables within,
+// only if they're cascading (mysql).
+// If table is not schema-qualified, schema is not checked.
+func applies(g ddb.Grant, table ddb.TableIdent) bool {
+	if g.Level != ddb.GrantLevelTable && !g.Cascades {
+		return false
+	}
+	if g.Schema != "" && table.Schema != "" && g.Schema != table.Schema {
+		return false
+	}
+	return g.Table == "" || g.Table == table.Name
+}
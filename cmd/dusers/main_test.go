@@ -0,0 +1,66 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/yznts/dsh/pkg/ddb"
+)
+
+func TestMemberships(t *testing.T) {
+	roles := map[string]ddb.Role{
+		"alice":   {Name: "alice", Inherit: true, MemberOf: []string{"dev"}},
+		"bob":     {Name: "bob", Inherit: false, MemberOf: []string{"dev"}},
+		"carol":   {Name: "carol", Inherit: true, MemberOf: []string{"auditor"}},
+		"dev":     {Name: "dev", Inherit: true, MemberOf: []string{"reader", "dev"}},
+		"auditor": {Name: "auditor", Inherit: false, MemberOf: []string{"reader"}},
+		"reader":  {Name: "reader", Inherit: true},
+	}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"reader", []string{"PUBLIC", "reader"}},
+		{"alice", []string{"PUBLIC", "alice", "dev", "reader"}},
+		{"bob", []string{"PUBLIC", "bob"}},
+		{"carol", []string{"PUBLIC", "auditor", "carol"}},
+		{"unknown", []string{"PUBLIC", "unknown"}},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for name := range memberships(tt.name, roles) {
+			got = append(got, name)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("memberships(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplies(t *testing.T) {
+	table := ddb.TableIdent{Schema: "app", Name: "users"}
+	tests := []struct {
+		name  string
+		grant ddb.Grant
+		table ddb.TableIdent
+		want  bool
+	}{
+		{"table", ddb.Grant{Level: ddb.GrantLevelTable, Schema: "app", Table: "users"}, table, true},
+		{"other table", ddb.Grant{Level: ddb.GrantLevelTable, Schema: "app", Table: "orders"}, table, false},
+		{"other schema", ddb.Grant{Level: ddb.GrantLevelTable, Schema: "log", Table: "users"}, table, false},
+		{"unqualified table", ddb.Grant{Level: ddb.GrantLevelTable, Schema: "log", Table: "users"}, ddb.TableIdent{Name: "users"}, true},
+		{"mysql global", ddb.Grant{Level: ddb.GrantLevelGlobal, Cascades: true}, table, true},
+		{"mysql schema", ddb.Grant{Level: ddb.GrantLevelSchema, Schema: "app", Cascades: true}, table, true},
+		{"mysql other schema", ddb.Grant{Level: ddb.GrantLevelSchema, Schema: "log", Cascades: true}, table, false},
+		{"postgres database", ddb.Grant{Level: ddb.GrantLevelDatabase, Privilege: "CONNECT"}, table, false},
+		{"postgres schema", ddb.Grant{Level: ddb.GrantLevelSchema, Schema: "app", Privilege: "USAGE"}, table, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applies(tt.grant, tt.table); got != tt.want {
+				t.Fatalf("applies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package dconf
+
+import (
+	"bufio"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackc/pgpassfile"
+	"go.kyoto.codes/zen/v3/logic"
+)
+
+// envDsn composes dsn from the standard client environment variables,
+// PG* for postgres and MYSQL_* for mysql (postgres takes precedence).
+// Returns empty string if none of them are set.
+func envDsn() string {
+	switch {
+	case logic.Or(os.Getenv("PGHOST"), os.Getenv("PGDATABASE"), os.Getenv("PGUSER")) != "":
+		return (&Connection{
+			Type:    "postgres",
+			Host:    os.Getenv("PGHOST"),
+			User:    os.Getenv("PGUSER"),
+			Pass:    os.Getenv("PGPASSWORD"),
+			DB:      os.Getenv("PGDATABASE"),
+			SslMode: os.Getenv("PGSSLMODE"),
+		}).envConn(os.Getenv("PGPORT"))
+	case logic.Or(os.Getenv("MYSQL_HOST"), os.Getenv("MYSQL_DATABASE"), os.Getenv("MYSQL_USER"), os.Getenv("MYSQL_UNIX_PORT")) != "":
+		return (&Connection{
+			Type:   "mysql",
+			Host:   os.Getenv("MYSQL_HOST"),
+			Socket: os.Getenv("MYSQL_UNIX_PORT"),
+			User:   os.Getenv("MYSQL_USER"),
+			Pass:   logic.Or(os.Getenv("MYSQL_PWD"), os.Getenv("MYSQL_PASSWORD")),
+			DB:     os.Getenv("MYSQL_DATABASE"),
+		}).envConn(logic.Or(os.Getenv("MYSQL_TCP_PORT"), os.Getenv("MYSQL_PORT")))
+	}
+	return ""
+}
+
+// envConn builds the dsn from the connection,
+// composed from environment variables.
+// Port is kept as is, and host is treated as a socket if it's a path (libpq way).
+func (c *Connection) envConn(port string) string {
+	if strings.HasPrefix(c.Host, "/") {
+		c.Socket, c.Host = c.Host, ""
+	}
+	if port != "" {
+		c.Host = net.JoinHostPort(logic.Or(c.Host, "localhost"), port)
+	}
+	return c.GetConn()
+}
+
+// withCredentials fills missing credentials from the standard client files:
+// ~/.pgpass (or PGPASSFILE) for postgres and ~/.my.cnf for mysql.
+// Returns true if dsn was changed.
+func withCredentials(dsnurl *url.URL) bool {
+	home, _ := os.UserHomeDir()
+	password, haspassword := "", false
+	if dsnurl.User != nil {
+		password, haspassword = dsnurl.User.Password()
+	}
+	switch dsnurl.Scheme {
+	case "postgres", "postgresql":
+		if haspassword {
+			return false
+		}
+		// Libpq ignores the file if it's accessible by others
+		path := logic.Or(os.Getenv("PGPASSFILE"), filepath.Join(home, ".pgpass"))
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm()&0077 != 0 {
+			return false
+		}
+		passfile, err := pgpassfile.ReadPassfile(path)
+		if err != nil {
+			return false
+		}
+		// Lookup with libpq defaults
+		user := logic.Or(dsnurl.User.Username(), os.Getenv("PGUSER"), os.Getenv("USER"))
+		password := passfile.FindPassword(
+			logic.Or(dsnurl.Hostname(), "localhost"),
+			logic.Or(dsnurl.Port(), "5432"),
+			logic.Or(strings.TrimPrefix(dsnurl.Path, "/"), user),
+			user,
+		)
+		if password == "" {
+			return false
+		}
+		dsnurl.User = url.UserPassword(user, password)
+		return true
+	case "mysql":
+		cnf := myCnf(filepath.Join(home, ".my.cnf"))
+		if len(cnf) == 0 {
+			return false
+		}
+		user := logic.Or(dsnurl.User.Username(), cnf["user"])
+		if !haspassword {
+			password = cnf["password"]
+		}
+		if user != "" || password != "" {
+			dsnurl.User = url.User(user)
+			if password != "" {
+				dsnurl.User = url.UserPassword(user, password)
+			}
+		}
+		// Server location, if not provided
+		q := dsnurl.Query()
+		if dsnurl.Host == "" && q.Get("socket") == "" {
+			if cnf["host"] != "" {
+				dsnurl.Host = cnf["host"]
+				if cnf["port"] != "" {
+					dsnurl.Host = net.JoinHostPort(cnf["host"], cnf["port"])
+				}
+			} else if cnf["socket"] != "" {
+				q.Set("socket", cnf["socket"])
+				dsnurl.RawQuery = q.Encode()
+			}
+		}
+		if strings.TrimPrefix(dsnurl.Path, "/") == "" && cnf["database"] != "" {
+			dsnurl.Path = "/" + cnf["database"]
+		}
+		return true
+	}
+	return false
+}
+
+// myCnf reads client options from the mysql option file,
+// [client] and [mysql] groups (latter takes precedence).
+// Returns nil if file can't be read.
+func myCnf(path string) map[string]string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	options := map[string]string{}
+	group := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// Skip comments and empty lines
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		// Group header
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if group != "client" && group != "mysql" {
+			continue
+		}
+		// Option, dashes and underscores are interchangeable
+		key, value, _ := strings.Cut(line, "=")
+		key = strings.ReplaceAll(strings.TrimSpace(key), "-", "_")
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		options[key] = value
+	}
+	return options
+}
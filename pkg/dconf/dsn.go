@@ -14,19 +14,36 @@ import (
 // sometimes it's a name from configuration file (expecting conf resolving).
 //
 // It tries to resolve dsn in the following order:
-// - if dsn is empty, it tries to resolve it from environment variable
-// - if dsn schema not found, it tries to resolve actual dsn from configuration file
-// - if dsn schema found, it returns dsn as is
+//   - if dsn is empty, it tries to resolve it from environment variables
+//     (DSN/DATABASE/DATABASE_URL, then standard PG* and MYSQL_* client variables)
+//   - if dsn is a path to SQLite database file, it's converted to URL
+//   - if dsn is in native format (libpq keyword/value, go-sql-driver), it's converted to URL
+//   - if dsn schema not found, it tries to resolve actual dsn from configuration file
+//   - if dsn schema found, it returns dsn as is,
+//     except missing credentials are filled from ~/.pgpass or ~/.my.cnf
 func GetDsn(dsn string) (string, error) {
-	// If dsn is empty, try to resolve it from environment variable
+	// If dsn is empty, try to resolve it from environment variables
 	dsn = logic.Or(dsn,
 		os.Getenv("DSN"),
 		os.Getenv("DATABASE"),
-		os.Getenv("DATABASE_URL"))
+		os.Getenv("DATABASE_URL"),
+		envDsn())
 	// If it's still empty, return an error
 	if dsn == "" {
 		return "", errors.New("dsn is empty")
 	}
+	// Convert database file path
+	if filedsn, ok := fileDsn(dsn); ok {
+		return filedsn, nil
+	}
+	// Convert native formats
+	native, ok, err := nativeDsn(dsn)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		dsn = native
+	}
 	// Parse dsn
 	dsnurl, err := url.Parse(dsn)
 	if err != nil {
@@ -46,6 +63,10 @@ func GetDsn(dsn string) (string, error) {
 			return "", errors.New("dsn schema not found")
 		}
 	}
+	// Fill missing credentials from client files
+	if withCredentials(dsnurl) {
+		return dsnurl.String(), nil
+	}
 	// Return dsn as is
 	return dsn, nil
 }
@@ -0,0 +1,77 @@
+package dconf
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.kyoto.codes/zen/v3/slice"
+)
+
+// sqliteMagic is a header string of every SQLite database file.
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// sqliteExts holds extensions of SQLite database files.
+var sqliteExts = []string{".db", ".sqlite", ".sqlite3"}
+
+// isSqliteFile checks if the path is an SQLite database file by its header.
+// Empty files are valid databases as well,
+// but only with a database extension (any empty file would match otherwise).
+func isSqliteFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err != nil || !info.Mode().IsRegular() {
+		return false
+	} else if info.Size() == 0 {
+		return slice.Contains(sqliteExts, strings.ToLower(filepath.Ext(path)))
+	}
+	header := make([]byte, len(sqliteMagic))
+	if _, err := io.ReadFull(file, header); err != nil {
+		return false
+	}
+	return bytes.Equal(header, sqliteMagic)
+}
+
+// fileDsn converts a database file path into dsn,
+// if it's an SQLite database file.
+// URI options might be appended to the path (e.g. ./app.db?immutable=1).
+// Returns false if it's not a database file.
+func fileDsn(path string) (string, bool) {
+	// Separate options, if any
+	options := ""
+	if !isSqliteFile(path) {
+		i := strings.LastIndexByte(path, '?')
+		if i < 0 || !isSqliteFile(path[:i]) {
+			return "", false
+		}
+		path, options = path[:i], path[i+1:]
+	}
+	// We're using absolute path,
+	// so it's not confused with the host part of the dsn
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	return (&url.URL{Scheme: "sqlite", Path: abs, RawQuery: options}).String(), true
+}
+
+// FileArg resolves a database file path, provided as the first tool argument
+// instead of -dsn flag (e.g. "dls ./app.db").
+// If dsn is empty and the first argument is a database file,
+// returns its dsn and the rest of arguments.
+// Otherwise, returns dsn and arguments as is.
+func FileArg(dsn string, args []string) (string, []string) {
+	if dsn != "" || len(args) == 0 {
+		return dsn, args
+	}
+	if filedsn, ok := fileDsn(args[0]); ok {
+		return filedsn, args[1:]
+	}
+	return dsn, args
+}
@@ -0,0 +1,70 @@
+package dconf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFileDsn(t *testing.T) {
+	dir := t.TempDir()
+	header := append([]byte(nil), sqliteMagic...)
+	os.WriteFile(filepath.Join(dir, "app.db"), append(header, make([]byte, 84)...), 0o600)
+	os.WriteFile(filepath.Join(dir, "app.data"), append(header, make([]byte, 84)...), 0o600)
+	os.WriteFile(filepath.Join(dir, "empty.sqlite3"), nil, 0o600)
+	os.WriteFile(filepath.Join(dir, "empty.txt"), nil, 0o600)
+	os.WriteFile(filepath.Join(dir, "notes.db"), []byte("not a database"), 0o600)
+	os.Mkdir(filepath.Join(dir, "dir.db"), 0o700)
+
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"app.db", "sqlite://" + filepath.Join(dir, "app.db"), true},
+		{"app.data", "sqlite://" + filepath.Join(dir, "app.data"), true},
+		{"empty.sqlite3", "sqlite://" + filepath.Join(dir, "empty.sqlite3"), true},
+		{"app.db?immutable=1", "sqlite://" + filepath.Join(dir, "app.db") + "?immutable=1", true},
+		{"app.db?mode=ro&cache=shared", "sqlite://" + filepath.Join(dir, "app.db") + "?mode=ro&cache=shared", true},
+		{"empty.txt", "", false},
+		{"notes.db", "", false},
+		{"dir.db", "", false},
+		{"missing.db", "", false},
+		{"missing.db?mode=rwc", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := fileDsn(filepath.Join(dir, tt.path))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("fileDsn(%q) = %s, %v, want %s, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFileArg(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "app.db")
+	os.WriteFile(db, nil, 0o600)
+
+	tests := []struct {
+		name     string
+		dsn      string
+		args     []string
+		wantdsn  string
+		wantargs []string
+	}{
+		{"file argument", "", []string{db, "users"}, "sqlite://" + db, []string{"users"}},
+		{"file argument only", "", []string{db}, "sqlite://" + db, []string{}},
+		{"dsn takes precedence", "postgres://db/app", []string{db, "users"}, "postgres://db/app", []string{db, "users"}},
+		{"not a file", "", []string{"users"}, "", []string{"users"}},
+		{"no arguments", "", nil, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, args := FileArg(tt.dsn, tt.args)
+			if dsn != tt.wantdsn || !reflect.DeepEqual(args, tt.wantargs) {
+				t.Errorf("FileArg() = %s, %q, want %s, %q", dsn, args, tt.wantdsn, tt.wantargs)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package dconf
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"go.kyoto.codes/zen/v3/logic"
+)
+
+// Native DSN formats detection.
+var (
+	// libpq keyword/value, like "host=localhost dbname=app"
+	keyValueRgx = regexp.MustCompile(`^\s*[a-z_]+\s*=`)
+	// go-sql-driver, like "user:password@tcp(localhost:3306)/app?parseTime=true"
+	mysqlRgx = regexp.MustCompile(`^(?:(.*)@)?(tcp|unix)\(([^)]*)\)/([^?]*)(?:\?(.*))?$`)
+)
+
+// nativeDsn converts native DSN formats (libpq keyword/value, go-sql-driver)
+// into our URL format.
+// Returns false if the format is not recognized.
+func nativeDsn(dsn string) (string, bool, error) {
+	// URLs are handled as is
+	if strings.Contains(dsn, "://") {
+		return "", false, nil
+	}
+	switch {
+	case keyValueRgx.MatchString(dsn):
+		dsnurl, err := keyValueDsn(dsn)
+		return dsnurl, err == nil, err
+	case mysqlRgx.MatchString(dsn):
+		return mysqlDsn(dsn), true, nil
+	}
+	return "", false, nil
+}
+
+// keyValueDsn converts libpq keyword/value connection string to postgres URL.
+// Values might be single-quoted, with backslash escapes.
+// Keywords without URL part are passed as query params.
+func keyValueDsn(dsn string) (string, error) {
+	dsnurl := &url.URL{Scheme: "postgres"}
+	q := url.Values{}
+	var user, password string
+	var haspassword bool
+	host, port := "", ""
+	rest := strings.TrimSpace(dsn)
+	for rest != "" {
+		// Keyword
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return "", fmt.Errorf("invalid connection string: missing value after %q", rest)
+		}
+		key := strings.TrimSpace(rest[:eq])
+		rest = strings.TrimLeftFunc(rest[eq+1:], unicode.IsSpace)
+		// Value, quoted or not.
+		// Backslash escapes the next character in both cases.
+		var value strings.Builder
+		quoted, closed := strings.HasPrefix(rest, "'"), false
+		i := logic.Tr(quoted, 1, 0)
+		for i < len(rest) {
+			c := rest[i]
+			if c == '\\' && i+1 < len(rest) {
+				value.WriteByte(rest[i+1])
+				i += 2
+				continue
+			}
+			if quoted && c == '\'' {
+				closed = true
+				i++
+				break
+			}
+			if !quoted && unicode.IsSpace(rune(c)) {
+				break
+			}
+			value.WriteByte(c)
+			i++
+		}
+		if quoted && !closed {
+			return "", fmt.Errorf("invalid connection string: unterminated quoted value of %s", key)
+		}
+		rest = rest[i:]
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		// Map to URL parts
+		switch val := value.String(); key {
+		case "host":
+			host = val
+		case "port":
+			port = val
+		case "dbname":
+			dsnurl.Path = "/" + val
+		case "user":
+			user = val
+		case "password":
+			password, haspassword = val, true
+		default:
+			q.Set(key, val)
+		}
+	}
+	// Socket directories are passed separately
+	if strings.HasPrefix(host, "/") {
+		q.Set("socket", host)
+		host = ""
+	}
+	dsnurl.Host = host
+	if port != "" {
+		dsnurl.Host += ":" + port
+	}
+	if user != "" || haspassword {
+		dsnurl.User = url.User(user)
+		if haspassword {
+			dsnurl.User = url.UserPassword(user, password)
+		}
+	}
+	dsnurl.RawQuery = q.Encode()
+	return dsnurl.String(), nil
+}
+
+// mysqlDsn converts go-sql-driver DSN to mysql URL.
+// Unix socket is passed as a socket param.
+func mysqlDsn(dsn string) string {
+	match := mysqlRgx.FindStringSubmatch(dsn)
+	userinfo, protocol, address, db, params := match[1], match[2], match[3], match[4], match[5]
+	dsnurl := &url.URL{Scheme: "mysql", Path: "/" + db, RawQuery: params}
+	if userinfo != "" {
+		user, password, haspassword := strings.Cut(userinfo, ":")
+		dsnurl.User = url.User(user)
+		if haspassword {
+			dsnurl.User = url.UserPassword(user, password)
+		}
+	}
+	if protocol == "unix" {
+		q := dsnurl.Query()
+		q.Set("socket", address)
+		dsnurl.RawQuery = q.Encode()
+	} else {
+		dsnurl.Host = address
+	}
+	return dsnurl.String()
+}
@@ -3,6 +3,7 @@ package dconf
 import (
 	"net/url"
 	"strconv"
+	"strings"
 
 	"go.kyoto.codes/zen/v3/slice"
 )
@@ -40,10 +41,21 @@ type Connection struct {
 	User    string `json:"user" yaml:"user"`
 	Pass    string `json:"pass" yaml:"pass"`
 	DB      string `json:"db" yaml:"db"`
+	Schema  string `json:"schema" yaml:"schema"` // Default schema (search_path), postgres only
+	Socket  string `json:"socket" yaml:"socket"` // Unix socket path (or its directory for postgres), used instead of host and port
 	SslMode string `json:"ssl_mode" yaml:"ssl_mode"`
 	SslCert string `json:"ssl_cert" yaml:"ssl_cert"`
 	SslKey  string `json:"ssl_key" yaml:"ssl_key"`
 	SslCa   string `json:"ssl_ca" yaml:"ssl_ca"`
+
+	// SSH tunnel settings (postgres and mysql only).
+	// Database host and port are resolved on the SSH server side.
+	// For the raw Conn, the same can be set with ssh_* DSN params.
+	SshHost       string `json:"ssh_host" yaml:"ssh_host"`               // SSH server address, port 22 by default
+	SshUser       string `json:"ssh_user" yaml:"ssh_user"`               // Current user by default
+	SshKey        string `json:"ssh_key" yaml:"ssh_key"`                 // Private key file
+	SshKnownHosts string `json:"ssh_known_hosts" yaml:"ssh_known_hosts"` // ~/.ssh/known_hosts by default
+	SshAgent      *bool  `json:"ssh_agent" yaml:"ssh_agent"`             // Authenticate with ssh-agent (SSH_AUTH_SOCK), used if available when omitted
 }
 
 // GetConn returns the connection string.
@@ -57,12 +69,24 @@ func (c *Connection) GetConn() string {
 	// Otherwise, build the DSN from the separate fields.
 	dsn := &url.URL{}
 	dsn.Scheme = c.Type
-	dsn.User = url.UserPassword(c.User, c.Pass)
+	// Password is omitted if not provided,
+	// so it can be resolved from client files (like ~/.pgpass).
+	if c.Pass != "" {
+		dsn.User = url.UserPassword(c.User, c.Pass)
+	} else if c.User != "" {
+		dsn.User = url.User(c.User)
+	}
 	dsn.Host = c.Host
 	if c.Port != 0 {
 		dsn.Host += ":" + strconv.Itoa(c.Port)
 	}
 	dsn.Path = c.DB
+	// Database name must be an absolute path for servers,
+	// otherwise it's taken as a host when host is omitted (e.g. for sockets).
+	// SQLite path is kept as is, it might be a relative one.
+	if c.DB != "" && slice.Contains([]string{"postgres", "postgresql", "mysql"}, c.Type) {
+		dsn.Path = "/" + strings.TrimPrefix(c.DB, "/")
+	}
 	q := dsn.Query()
 	// Query parameters format may vary depending on the driver.
 	switch {
@@ -71,12 +95,40 @@ func (c *Connection) GetConn() string {
 		q.Add("sslcert", c.SslCert)
 		q.Add("sslkey", c.SslKey)
 		q.Add("sslrootcert", c.SslCa)
+		// Empty search_path is valid for postgres,
+		// so we're adding it only if provided.
+		if c.Schema != "" {
+			q.Add("search_path", c.Schema)
+		}
 	case slice.Contains([]string{"mysql"}, c.Type):
 		q.Add("ssl_mode", c.SslMode)
 		q.Add("ssl_cert", c.SslCert)
 		q.Add("ssl_key", c.SslKey)
 		q.Add("ssl_ca", c.SslCa)
 	}
+	// Socket is the same for all drivers
+	if c.Socket != "" {
+		q.Add("socket", c.Socket)
+	}
+	// SSH tunnel parameters are the same for all drivers,
+	// we're adding them only if tunnel is requested.
+	if c.SshHost != "" {
+		q.Add("ssh_host", c.SshHost)
+		q.Add("ssh_user", c.SshUser)
+		q.Add("ssh_key", c.SshKey)
+		q.Add("ssh_known_hosts", c.SshKnownHosts)
+		// Omitted agent setting is not the same as disabled one,
+		// so it's added only if explicitly set.
+		if c.SshAgent != nil {
+			q.Add("ssh_agent", strconv.FormatBool(*c.SshAgent))
+		}
+	}
+	// Empty values are dropped, so driver defaults are applied
+	for key, vals := range q {
+		if len(vals) == 1 && vals[0] == "" {
+			q.Del(key)
+		}
+	}
 	dsn.RawQuery = q.Encode()
 	return dsn.String()
 }
@@ -0,0 +1,45 @@
+package dconf
+
+import "testing"
+
+func TestConnectionGetConn(t *testing.T) {
+	enabled, disabled := true, false
+	tests := []struct {
+		name string
+		conn Connection
+		want string
+	}{
+		{
+			"postgres",
+			Connection{Type: "postgres", Host: "db", Port: 5432, User: "app", Pass: "secret", DB: "app", SslMode: "disable"},
+			"postgres://app:secret@db:5432/app?sslmode=disable",
+		},
+		{
+			"socket without host",
+			Connection{Type: "postgres", DB: "app", Socket: "/run/postgresql"},
+			"postgres:///app?socket=%2Frun%2Fpostgresql",
+		},
+		{
+			"ssh agent omitted",
+			Connection{Type: "mysql", Host: "db", DB: "app", SshHost: "bastion"},
+			"mysql://db/app?ssh_host=bastion",
+		},
+		{
+			"ssh agent disabled",
+			Connection{Type: "mysql", Host: "db", DB: "app", SshHost: "bastion", SshAgent: &disabled},
+			"mysql://db/app?ssh_agent=false&ssh_host=bastion",
+		},
+		{
+			"ssh agent enabled",
+			Connection{Type: "mysql", Host: "db", DB: "app", SshHost: "bastion", SshAgent: &enabled},
+			"mysql://db/app?ssh_agent=true&ssh_host=bastion",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.conn.GetConn(); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
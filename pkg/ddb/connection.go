@@ -3,9 +3,14 @@
 package ddb
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"net/url"
 	"reflect"
+	"sync"
+
+	"go.kyoto.codes/zen/v3/slice"
 )
 
 // Connection is a wrapper around sql.DB that also stores the DSN and scheme.
@@ -15,6 +20,50 @@ type Connection struct {
 
 	DSN    *url.URL
 	Scheme string
+
+	// tx holds the active transaction, if any.
+	// While it's set, all statements are running within it.
+	// Methods might be called concurrently (e.g. by the daemon),
+	// so access is guarded by txmu.
+	tx   *sql.Tx
+	txmu sync.Mutex
+
+	// tunnel holds SSH tunnel, if connection goes through it.
+	tunnel *tunnel
+}
+
+// Close closes the connection pool and the tunnel, if any.
+// Active transaction is rolled back, so it doesn't outlive the connection.
+func (c *Connection) Close() error {
+	c.txmu.Lock()
+	if c.tx != nil {
+		c.tx.Rollback()
+		c.tx = nil
+	}
+	c.txmu.Unlock()
+	err := c.DB.Close()
+	if c.tunnel != nil {
+		c.tunnel.Close()
+	}
+	return err
+}
+
+// querier is a common interface of sql.DB, sql.Conn and sql.Tx,
+// so statements can run on any of them.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// querier returns the active transaction, if any.
+// Otherwise, it returns the connection pool.
+func (c *Connection) querier() querier {
+	c.txmu.Lock()
+	defer c.txmu.Unlock()
+	if c.tx != nil {
+		return c.tx
+	}
+	return c.DB
 }
 
 // QueryData is a database-agnostic method that queries the database
@@ -23,56 +72,264 @@ type Connection struct {
 // The Data struct contains the columns and rows of the result.
 // Method is returning a pointer to avoid copying the Data struct,
 // which might be large.
+func (c *Connection) QueryData(query string, args ...any) (*Data, error) {
+	return c.QueryDataContext(context.Background(), query, args...)
+}
+
+// QueryDataContext is a context-aware version of QueryData.
+//
+// It's a buffered version of QueryStreamContext,
+// so all type assertion details are described there.
+func (c *Connection) QueryDataContext(ctx context.Context, query string, args ...any) (*Data, error) {
+	stream, err := c.QueryStreamContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return ReadStream(stream)
+}
+
+// QueryStream is a database-agnostic method that queries the database
+// with the given query and returns the result as a Stream.
+func (c *Connection) QueryStream(query string, args ...any) (Stream, error) {
+	return c.QueryStreamContext(context.Background(), query, args...)
+}
+
+// QueryStreamContext is a context-aware version of QueryStream.
+// Context is applied to the whole stream lifetime,
+// so canceling it will interrupt the iteration.
 //
 // This exact implementation is the most generic one.
 // It utilizes 'any' type to store the values of the result
 // and leaves all type assertion to the underlying driver.
 // For some databases, like MySQL, we might need to override this method.
-func (c *Connection) QueryData(query string) (*Data, error) {
+func (c *Connection) QueryStreamContext(ctx context.Context, query string, args ...any) (Stream, error) {
 	// Execute the query.
-	rows, err := c.Query(query)
+	rows, err := c.querier().QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	// Get columns information.
-	cols, err := rows.Columns()
+	// Compose the stream
+	stream := &rowsStream{
+		rows: rows,
+		target: func(*sql.ColumnType) any {
+			// We're using new(any) here as the most generic solution,
+			// so we're leaving the type assertion to the driver.
+			// In some cases (like MySQL) we will need to override QueryStream method
+			// to handle type assertion correctly.
+			//
+			// We're not using col.ScanType() here because it's not always correct.
+			// For example, postgres driver doesn't report nullable types correctly (sql.NullString).
+			return new(any)
+		},
+		value: func(ptr any) any {
+			// Get value from the pointer
+			return reflect.ValueOf(ptr).Elem().Interface()
+		},
+	}
+	// Get columns information of the first result set
+	if err := stream.columns(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	return stream, nil
+}
+
+// Execute is a database-agnostic method that executes the statement
+// without returning rows (DML/DDL) and returns execution summary.
+func (c *Connection) Execute(query string, args ...any) (*Result, error) {
+	return c.ExecuteContext(context.Background(), query, args...)
+}
+
+// ExecuteContext is a context-aware version of Execute.
+func (c *Connection) ExecuteContext(ctx context.Context, query string, args ...any) (*Result, error) {
+	res, err := c.querier().ExecContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
-	// Initialize the Data struct.
-	// It holds both the columns and rows of the result.
-	data := &Data{
-		Cols: cols,
-	}
-	// Define scan target row.
-	// This is a slice of pointers,
-	// so we need to copy values on each iteration.
-	var scan []any
-	for range cols {
-		// We're using new(any) here as the most generic solution,
-		// so we're leaving the type assertion to the driver.
-		// In some cases (like MySQL) we will need to override QueryData method
-		// to handle type assertion correctly.
-		//
-		// We're not using col.ScanType() here because it's not always correct.
-		// For example, postgres driver doesn't report nullable types correctly (sql.NullString).
-		scan = append(scan, new(any))
-	}
-	for rows.Next() {
+	return newResult(res), nil
+}
+
+// Begin starts a transaction.
+// All following statements are running within it (on a single connection),
+// until Commit or Rollback is called.
+func (c *Connection) Begin() error {
+	return c.BeginContext(context.Background())
+}
+
+// BeginContext is a context-aware version of Begin.
+// Context is applied to the whole transaction lifetime,
+// so canceling it rolls the transaction back.
+func (c *Connection) BeginContext(ctx context.Context) error {
+	c.txmu.Lock()
+	defer c.txmu.Unlock()
+	if c.tx != nil {
+		return errors.New("transaction is already in progress")
+	}
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	c.tx = tx
+	return nil
+}
+
+// Commit commits the active transaction.
+func (c *Connection) Commit() error {
+	c.txmu.Lock()
+	tx := c.tx
+	c.tx = nil
+	c.txmu.Unlock()
+	if tx == nil {
+		return errors.New("no transaction in progress")
+	}
+	return tx.Commit()
+}
+
+// Rollback rolls the active transaction back.
+func (c *Connection) Rollback() error {
+	c.txmu.Lock()
+	tx := c.tx
+	c.tx = nil
+	c.txmu.Unlock()
+	if tx == nil {
+		return errors.New("no transaction in progress")
+	}
+	return tx.Rollback()
+}
+
+// newColumnType composes column metadata from sql.ColumnType.
+func newColumnType(col *sql.ColumnType) ColumnType {
+	t := ColumnType{
+		DatabaseType: col.DatabaseTypeName(),
+	}
+	t.Nullable, t.HasNullable = col.Nullable()
+	t.Length, t.HasLength = col.Length()
+	t.Precision, t.Scale, t.HasPrecision = col.DecimalSize()
+	return t
+}
+
+// newResult composes execution summary from sql.Result.
+// Some drivers don't support some of the values (e.g. LastInsertId in postgres),
+// so we're ignoring errors and leaving zero values.
+func newResult(res sql.Result) *Result {
+	affected, _ := res.RowsAffected()
+	lastid, _ := res.LastInsertId()
+	return &Result{
+		RowsAffected: affected,
+		LastInsertId: lastid,
+	}
+}
+
+// QuoteIdent quotes an identifier (table, column, etc.)
+// according to the database dialect.
+func (c *Connection) QuoteIdent(ident string) string {
+	return quoteIdent(c.Scheme, ident)
+}
+
+// SplitStatements splits the script into separate statements
+// according to the database dialect.
+func (c *Connection) SplitStatements(script string) []string {
+	return splitStatements(c.Scheme, script)
+}
+
+// rowsStream is a Stream implementation on top of sql.Rows.
+// Scan targets and value extraction are provided by the caller,
+// because drivers are handling type assertion differently.
+type rowsStream struct {
+	rows  *sql.Rows
+	cols  []string
+	types []ColumnType
+
+	target func(*sql.ColumnType) any // Creates a scan target pointer for the column
+	value  func(any) any             // Extracts exact value from the scan pointer
+	close  func() error              // Optional cleanup, called after rows are closed
+
+	scan []any // Scan target row, slice of pointers
+	data *Data
+	err  error
+}
+
+// columns reads columns information of the current result set
+// and prepares the scan target row.
+// This is a slice of pointers,
+// so we need to copy values on each iteration.
+func (s *rowsStream) columns() error {
+	cols, err := s.rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	s.cols = slice.Map(cols, func(c *sql.ColumnType) string { return c.Name() })
+	s.types = slice.Map(cols, newColumnType)
+	s.scan = slice.Map(cols, s.target)
+	return nil
+}
+
+func (s *rowsStream) Cols() []string {
+	return s.cols
+}
+
+func (s *rowsStream) Types() []ColumnType {
+	return s.types
+}
+
+func (s *rowsStream) Next() bool {
+	// Initialize a new chunk.
+	// We're not reusing the previous one,
+	// because consumer might still hold a reference to it.
+	s.data = &Data{
+		Cols:  s.cols,
+		Types: s.types,
+	}
+	for len(s.data.Rows) < StreamChunk && s.rows.Next() {
 		// Scan the row into prepared pointers
-		err = rows.Scan(scan...)
-		if err != nil {
-			return nil, err
+		if s.err = s.rows.Scan(s.scan...); s.err != nil {
+			return false
 		}
-		// Copy exact values from the pointers to the Data struct
+		// Copy exact values from the pointers to the chunk
 		var row []any
-		for _, ptr := range scan {
-			// Get value from the pointer and append it to the row
-			row = append(row, reflect.ValueOf(ptr).Elem().Interface())
+		for _, ptr := range s.scan {
+			row = append(row, s.value(ptr))
 		}
-		// Append the row to the Data holder
-		data.Rows = append(data.Rows, row)
+		// Append the row to the chunk
+		s.data.Rows = append(s.data.Rows, row)
+	}
+	// Check for iteration errors
+	if s.err = s.rows.Err(); s.err != nil {
+		return false
+	}
+	// Empty chunk means there are no more rows
+	return len(s.data.Rows) > 0
+}
+
+func (s *rowsStream) NextResultSet() bool {
+	// Don't proceed after failure
+	if s.err != nil {
+		return false
+	}
+	// Advance, remaining rows of the current set are discarded
+	if !s.rows.NextResultSet() {
+		s.err = s.rows.Err()
+		return false
+	}
+	// Columns are different for each set
+	if s.err = s.columns(); s.err != nil {
+		return false
+	}
+	return true
+}
+
+func (s *rowsStream) Data() *Data {
+	return s.data
+}
+
+func (s *rowsStream) Err() error {
+	return s.err
+}
+
+func (s *rowsStream) Close() error {
+	err := s.rows.Close()
+	if s.close != nil {
+		return errors.Join(err, s.close())
 	}
-	return data, nil
+	return err
 }
@@ -0,0 +1,35 @@
+package ddb
+
+import "strings"
+
+// quoteIdent quotes an identifier according to the scheme's dialect.
+// Quote characters inside the identifier are escaped by doubling,
+// so the result is safe to interpolate into a query.
+//
+// It's shared between direct and daemon connections,
+// that's why it depends on the scheme instead of the connection type.
+func quoteIdent(scheme string, ident string) string {
+	switch scheme {
+	case "mysql":
+		return "`" + strings.ReplaceAll(ident, "`", "``") + "`"
+	default:
+		// SQLite and Postgres are following SQL standard here
+		return `"` + strings.ReplaceAll(ident, `"`, `""`) + `"`
+	}
+}
+
+// unquoteIdent removes the identifier quotes (double quotes, backticks or brackets),
+// unescaping doubled quotes inside.
+// Unquoted identifier is returned as-is.
+func unquoteIdent(ident string) string {
+	if len(ident) < 2 {
+		return ident
+	}
+	switch first, last := ident[0], ident[len(ident)-1]; {
+	case first == '"' && last == '"', first == '`' && last == '`':
+		return strings.ReplaceAll(ident[1:len(ident)-1], string(first)+string(first), string(first))
+	case first == '[' && last == ']':
+		return ident[1 : len(ident)-1]
+	}
+	return ident
+}
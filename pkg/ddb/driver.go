@@ -0,0 +1,137 @@
+package ddb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Driver is a database connection factory,
+// registered for one or more DSN schemes with Register.
+// It receives already parsed DSN.
+// Context bounds connection establishment, if driver dials on open.
+//
+// Returned database is validated by Open with PingContext method (if implemented).
+// Driver may override it to report typed connection errors (ErrAuth, etc).
+type Driver func(ctx context.Context, dsn *url.URL) (Database, error)
+
+// DefaultOpenTimeout is a connection validation timeout, used by Open.
+// Use OpenContext to control it.
+const DefaultOpenTimeout = 10 * time.Second
+
+// Registered drivers, by DSN scheme.
+var (
+	driversmu sync.RWMutex
+	drivers   = map[string]Driver{}
+)
+
+// Register makes a driver available by the provided DSN schemes.
+// It's meant to be called from init functions,
+// so extra drivers can be compiled in with a blank import
+// from a separate package (see dext package).
+// It panics if the scheme is already registered, same as sql.Register does.
+func Register(driver Driver, schemes ...string) {
+	driversmu.Lock()
+	defer driversmu.Unlock()
+	if driver == nil {
+		panic("ddb: register driver is nil")
+	}
+	for _, scheme := range schemes {
+		if _, dup := drivers[scheme]; dup {
+			panic("ddb: register called twice for scheme " + scheme)
+		}
+		drivers[scheme] = driver
+	}
+}
+
+// Drivers returns a sorted list of registered DSN schemes.
+func Drivers() []string {
+	driversmu.RLock()
+	defer driversmu.RUnlock()
+	schemes := make([]string, 0, len(drivers))
+	for scheme := range drivers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Open opens a database connection based on the provided DSN.
+// It's a shortcut for OpenContext with DefaultOpenTimeout.
+func Open(dsn string) (Database, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultOpenTimeout)
+	defer cancel()
+	return OpenContext(ctx, dsn)
+}
+
+// OpenContext opens a database connection based on the provided DSN.
+// Driver is resolved by the DSN scheme from registered ones.
+// For now, DSN must be a valid URL.
+// This must to be improved in the future.
+//
+// Connection is validated with a ping,
+// so bad credentials or unreachable server are reported right away.
+// Transient failures (ErrNetwork) are retried with backoff
+// until the context is done, which is useful while the server is starting up.
+func OpenContext(ctx context.Context, dsn string) (Database, error) {
+	// Validate and parse dsn
+	if dsn == "" {
+		return nil, errors.New("empty DSN")
+	}
+	dsnurl, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	// Resolve the driver
+	driversmu.RLock()
+	driver, ok := drivers[dsnurl.Scheme]
+	driversmu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported database: %q", dsnurl.Scheme)
+	}
+	db, err := driver(ctx, dsnurl)
+	if err != nil {
+		return nil, err
+	}
+	// Validate the connection, if possible
+	if err := ping(ctx, db); err != nil {
+		if db, iscloser := db.(io.Closer); iscloser {
+			db.Close()
+		}
+		return nil, err
+	}
+	return db, nil
+}
+
+// ping validates the database connection,
+// retrying on transient failures with exponential backoff.
+func ping(ctx context.Context, db Database) error {
+	pinger, ok := db.(interface{ PingContext(context.Context) error })
+	if !ok {
+		return nil
+	}
+	backoff := 100 * time.Millisecond
+	for {
+		err := pinger.PingContext(ctx)
+		// Type plain network errors, if driver didn't
+		if err != nil && !isConnError(err) && isNetError(err) {
+			err = connError(ErrNetwork, err)
+		}
+		if err == nil || !errors.Is(err, ErrNetwork) {
+			return err
+		}
+		// Wait before the next attempt,
+		// giving up with the last error when the context is done.
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 2*time.Second)
+	}
+}
@@ -0,0 +1,167 @@
+package ddb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testDatabase is a database stub,
+// failing the pings with the given errors one by one,
+// and with the last one after that.
+type testDatabase struct {
+	Database
+	errs   []error
+	pings  int
+	closed bool
+}
+
+func (d *testDatabase) PingContext(ctx context.Context) error {
+	d.pings++
+	if len(d.errs) == 0 {
+		return nil
+	}
+	err := d.errs[0]
+	if len(d.errs) > 1 {
+		d.errs = d.errs[1:]
+	}
+	return err
+}
+
+func (d *testDatabase) Close() error {
+	d.closed = true
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	driver := func(ctx context.Context, dsn *url.URL) (Database, error) {
+		return &testDatabase{}, nil
+	}
+	Register(driver, "test-register-b", "test-register-a")
+	schemes := Drivers()
+	if !slices.IsSorted(schemes) {
+		t.Fatalf("drivers are not sorted: %v", schemes)
+	}
+	for _, scheme := range []string{"test-register-a", "test-register-b"} {
+		if !slices.Contains(schemes, scheme) {
+			t.Fatalf("scheme %s is not registered: %v", scheme, schemes)
+		}
+	}
+	// Duplicate and nil drivers are programming errors
+	for name, register := range map[string]func(){
+		"duplicate": func() { Register(driver, "test-register-a") },
+		"nil":       func() { Register(nil, "test-register-nil") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s registration doesn't panic", name)
+				}
+			}()
+			register()
+		}()
+	}
+}
+
+func TestOpenContext(t *testing.T) {
+	// Driver returns the database, prepared by the test case
+	var db *testDatabase
+	Register(func(ctx context.Context, dsn *url.URL) (Database, error) {
+		if dsn.Host == "fail" {
+			return nil, errors.New("driver failed")
+		}
+		return db, nil
+	}, "test-open")
+
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		name    string
+		dsn     string
+		errs    []error // Ping errors, the last one is repeated
+		timeout time.Duration
+		kind    error // Expected error kind, nil if no error expected
+		pings   int
+	}{
+		{"connected", "test-open://db", nil, time.Second, nil, 1},
+		{"retried until available", "test-open://db", []error{refused, io.EOF, connError(ErrNetwork, errors.New("starting up")), nil}, 5 * time.Second, nil, 4},
+		{"auth is not retried", "test-open://db", []error{connError(ErrAuth, errors.New("bad password"))}, time.Second, ErrAuth, 1},
+		{"missing database is not retried", "test-open://db", []error{connError(ErrDatabaseMissing, errors.New("no db"))}, time.Second, ErrDatabaseMissing, 1},
+		{"untyped error is not retried", "test-open://db", []error{errors.New("syntax")}, time.Second, errors.New("syntax"), 1},
+		{"network timeout", "test-open://db", []error{refused}, 250 * time.Millisecond, ErrNetwork, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db = &testDatabase{errs: tt.errs}
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			opened, err := OpenContext(ctx, tt.dsn)
+			switch {
+			case tt.kind == nil && err != nil:
+				t.Fatalf("unexpected error %v", err)
+			case tt.kind == nil:
+				if opened != db || db.closed {
+					t.Fatal("opened database is not returned as is")
+				}
+			case err == nil:
+				t.Fatal("expected an error")
+			case isConnError(tt.kind) && !errors.Is(err, tt.kind):
+				t.Fatalf("expected %v, got %v", tt.kind, err)
+			case !db.closed:
+				t.Fatal("database is not closed on failure")
+			}
+			if tt.pings > 0 && db.pings != tt.pings {
+				t.Fatalf("expected %d pings, got %d", tt.pings, db.pings)
+			}
+		})
+	}
+
+	// Errors before the driver is involved
+	for dsn, msg := range map[string]string{
+		"":                  "empty DSN",
+		"test-unknown://db": "unsupported database",
+		"test-open://fail":  "driver failed",
+	} {
+		if _, err := OpenContext(context.Background(), dsn); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("OpenContext(%q) = %v, want %q error", dsn, err, msg)
+		}
+	}
+}
+
+func TestIsNetError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"dial", &net.OpError{Op: "dial", Err: errors.New("refused")}, true},
+		{"dns", &net.DNSError{Err: "no such host", Name: "db"}, true},
+		{"refused", syscall.ECONNREFUSED, true},
+		{"reset", errors.Join(errors.New("read"), syscall.ECONNRESET), true},
+		{"eof", io.EOF, true},
+		{"wrapped eof", connError(ErrAuth, io.ErrUnexpectedEOF), true},
+		{"other", errors.New("password authentication failed"), false},
+	}
+	for _, tt := range tests {
+		if got := isNetError(tt.err); got != tt.want {
+			t.Errorf("%s: isNetError() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConnError(t *testing.T) {
+	cause := errors.New("password authentication failed")
+	err := connError(ErrAuth, cause)
+	if !errors.Is(err, ErrAuth) || !errors.Is(err, cause) {
+		t.Fatal("both kind and cause must be in the chain")
+	}
+	if !isConnError(err) || isConnError(cause) {
+		t.Fatal("only typed errors are connection errors")
+	}
+}
@@ -0,0 +1,45 @@
+package ddb
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+)
+
+// Connection errors, returned by Open wrapped together with the driver error.
+// Use errors.Is to check the kind.
+var (
+	ErrAuth            = errors.New("authentication failed")
+	ErrNetwork         = errors.New("connection failed") // Network or server availability failure, retried by Open
+	ErrDatabaseMissing = errors.New("database does not exist")
+)
+
+// connError wraps the driver error with the kind,
+// keeping both in the chain.
+func connError(kind error, err error) error {
+	return fmt.Errorf("%w: %w", kind, err)
+}
+
+// isConnError reports whether the error is already typed with connError.
+func isConnError(err error) bool {
+	return errors.Is(err, ErrAuth) || errors.Is(err, ErrNetwork) || errors.Is(err, ErrDatabaseMissing)
+}
+
+// isNetError reports whether the error is a network failure,
+// like refused connection, unresolved host or a connection dropped by the server.
+func isNetError(err error) bool {
+	var (
+		operr  *net.OpError
+		dnserr *net.DNSError
+	)
+	return errors.As(err, &operr) ||
+		errors.As(err, &dnserr) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, driver.ErrBadConn)
+}
@@ -0,0 +1,166 @@
+package ddb
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// TableIdent identifies a table by schema and name.
+// Empty schema means the default one
+// (search_path for postgres, current database for mysql, main for sqlite).
+//
+// Both parts are stored unquoted, exactly as the database stores them,
+// so mixed-case names are preserved.
+type TableIdent struct {
+	Schema string
+	Name   string
+}
+
+// ParseTableIdent parses a table identifier provided by the user,
+// like `users`, `app.users` or `"App"."Users"`.
+//
+// Unlike SQL, unquoted parts are taken as-is (without case folding),
+// so names can be copied directly from dls output.
+// Quotes are only needed for names containing dots or quotes.
+// Both double quotes and backticks are supported.
+func ParseTableIdent(ident string) (TableIdent, error) {
+	var (
+		parts  []string
+		part   strings.Builder
+		quote  rune // Current quote character, zero if outside of quotes
+		quoted bool // Whether current part was quoted
+	)
+	runes := []rune(ident)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0 && r == quote:
+			// Doubled quote is an escaped quote character
+			if i+1 < len(runes) && runes[i+1] == quote {
+				part.WriteRune(r)
+				i++
+				continue
+			}
+			quote = 0
+		case quote != 0:
+			part.WriteRune(r)
+		case r == '"' || r == '`':
+			if part.Len() > 0 || quoted {
+				return TableIdent{}, errors.New("unexpected quote in table identifier")
+			}
+			quote, quoted = r, true
+		case r == '.':
+			parts = append(parts, part.String())
+			part.Reset()
+			quoted = false
+		default:
+			if quoted {
+				return TableIdent{}, errors.New("unexpected character after quoted table identifier part")
+			}
+			part.WriteRune(r)
+		}
+	}
+	if quote != 0 {
+		return TableIdent{}, errors.New("unterminated quote in table identifier")
+	}
+	parts = append(parts, part.String())
+	// Validate and compose
+	for _, p := range parts {
+		if p == "" {
+			return TableIdent{}, errors.New("empty table identifier part")
+		}
+	}
+	switch len(parts) {
+	case 1:
+		return TableIdent{Name: parts[0]}, nil
+	case 2:
+		return TableIdent{Schema: parts[0], Name: parts[1]}, nil
+	default:
+		return TableIdent{}, errors.New("table identifier must be in [schema.]table format")
+	}
+}
+
+// String returns a human-readable representation of the identifier.
+// It's not quoted, so don't use it in queries.
+func (t TableIdent) String() string {
+	if t.Schema == "" {
+		return t.Name
+	}
+	return t.Schema + "." + t.Name
+}
+
+// Quoted returns the identifier, quoted with the provided function
+// (usually Database.QuoteIdent), so it's safe to use in queries.
+func (t TableIdent) Quoted(quote func(string) string) string {
+	if t.Schema == "" {
+		return quote(t.Name)
+	}
+	return quote(t.Schema) + "." + quote(t.Name)
+}
+
+// WithSchema applies the default schema to the DSN,
+// so unqualified names are resolved within it for the whole session.
+// For postgres it's a search_path, for mysql it's a database.
+// SQLite schemas are attached databases, so there is nothing to apply.
+func WithSchema(dsn string, schema string) (string, error) {
+	if schema == "" {
+		return dsn, nil
+	}
+	dsnurl, err := url.Parse(dsn)
+	if err != nil {
+		return "", err
+	}
+	switch dsnurl.Scheme {
+	case "postgres", "postgresql":
+		q := dsnurl.Query()
+		q.Set("search_path", schema)
+		dsnurl.RawQuery = q.Encode()
+	case "mysql":
+		dsnurl.Path = "/" + schema
+	}
+	return dsnurl.String(), nil
+}
+
+// WithDatabase switches the DSN to another database on the same server,
+// so there is no need to rewrite the DSN path by hand.
+// SQLite database is a file, so there is nothing to switch to.
+func WithDatabase(dsn string, database string) (string, error) {
+	if database == "" {
+		return dsn, nil
+	}
+	dsnurl, err := url.Parse(dsn)
+	if err != nil {
+		return "", err
+	}
+	switch dsnurl.Scheme {
+	case "postgres", "postgresql", "mysql":
+		dsnurl.Path = "/" + database
+	default:
+		return "", fmt.Errorf("database switching is not supported for %s", dsnurl.Scheme)
+	}
+	return dsnurl.String(), nil
+}
+
+// WithReadOnly makes the DSN read-only, if supported.
+// For now, it's SQLite only (mode=ro), unless mode or immutable option is already set.
+// It's used by introspection tools, so they never modify (or create) database files.
+func WithReadOnly(dsn string) (string, error) {
+	dsnurl, err := url.Parse(dsn)
+	if err != nil {
+		return "", err
+	}
+	switch dsnurl.Scheme {
+	case "sqlite", "sqlite3":
+		q := dsnurl.Query()
+		if q.Has("mode") || q.Has("immutable") {
+			return dsn, nil
+		}
+		q.Set("mode", "ro")
+		dsnurl.RawQuery = q.Encode()
+	default:
+		return dsn, nil
+	}
+	return dsnurl.String(), nil
+}
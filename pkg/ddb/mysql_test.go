@@ -0,0 +1,186 @@
+//go:build !daemon
+
+package ddb
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA generates a self-signed CA and writes it to the PEM file.
+func testCA(t *testing.T, path string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ddb test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// testServerCert generates a server certificate for the host, signed by the CA.
+func testServerCert(t *testing.T, host string, ca *x509.Certificate, cakey *ecdsa.PrivateKey) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, cakey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// testHandshake runs a TLS handshake between the client config
+// and the server with provided certificate.
+func testHandshake(config *tls.Config, cert tls.Certificate) error {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go tls.Server(server, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+	return tls.Client(client, config).Handshake()
+}
+
+func TestMysqlTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	// Server certificate is issued for another host,
+	// so hostname verification must fail
+	ca, cakey := testCA(t, filepath.Join(dir, "ca.pem"))
+	cert := testServerCert(t, "db.example.com", ca, cakey)
+	// Another CA, not trusted by the client
+	testCA(t, filepath.Join(dir, "other.pem"))
+	// CA file without certificates
+	os.WriteFile(filepath.Join(dir, "empty.pem"), []byte("not a certificate"), 0o600)
+
+	tests := []struct {
+		name    string
+		mode    string
+		ca      string
+		config  bool // Config is expected to be composed
+		connect bool // Handshake is expected to succeed
+	}{
+		{"verify_ca accepts hostname mismatch", "verify_ca", "ca.pem", true, true},
+		{"verify_ca rejects untrusted ca", "verify_ca", "other.pem", true, false},
+		{"verify_identity rejects hostname mismatch", "verify_identity", "ca.pem", true, false},
+		{"required skips verification", "required", "", true, true},
+		{"preferred skips verification", "preferred", "other.pem", true, true},
+		{"unknown mode", "verify_everything", "ca.pem", false, false},
+		{"ca without certificates", "verify_ca", "empty.pem", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ca := ""
+			if tt.ca != "" {
+				ca = filepath.Join(dir, tt.ca)
+			}
+			config, err := mysqlTLSConfig(tt.mode, "127.0.0.1", ca, "", "")
+			if !tt.config {
+				if err == nil {
+					t.Fatal("expected an error, got config")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = testHandshake(config, cert)
+			if tt.connect && err != nil {
+				t.Fatalf("expected handshake to succeed, got %v", err)
+			}
+			if !tt.connect && err == nil {
+				t.Fatal("expected handshake to fail")
+			}
+		})
+	}
+}
+
+func TestMysqlTLS(t *testing.T) {
+	dir := t.TempDir()
+	testCA(t, filepath.Join(dir, "ca.pem"))
+	ca := filepath.Join(dir, "ca.pem")
+
+	tests := []struct {
+		name     string
+		query    string
+		key      string // Expected key, "registered" for any registered config
+		fallback bool   // Plaintext fallback is expected
+		err      bool
+	}{
+		{"no params", "parseTime=true", "", false, false},
+		{"disabled", "ssl_mode=disabled", "false", false, false},
+		{"implicit verify_ca", "ssl_ca=" + ca, "registered", false, false},
+		{"preferred keeps fallback", "ssl_mode=preferred&ssl_ca=" + ca, "registered", true, false},
+		{"postgres-like name", "ssl_mode=verify-full&ssl_ca=" + ca, "registered", false, false},
+		{"unknown mode", "ssl_mode=sometimes", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, err := mysqlTLS(query, "localhost")
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.key == "registered" && key == "" || tt.key != "registered" && key != tt.key {
+				t.Fatalf("unexpected key %q", key)
+			}
+			for _, param := range []string{"ssl_mode", "ssl_ca", "ssl_cert", "ssl_key"} {
+				if query.Has(param) {
+					t.Fatalf("param %s is not removed", param)
+				}
+			}
+			if query.Has("allowFallbackToPlaintext") != tt.fallback {
+				t.Fatalf("unexpected fallback in %s", query.Encode())
+			}
+		})
+	}
+}
@@ -3,30 +3,67 @@
 package ddb
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"net/rpc"
+	"net/url"
 	"os/exec"
+	"strings"
 	"time"
 )
 
-func Open(dsn string) (Database, error) {
-	// Start daemon
-	cmd := exec.Command("dconn", "-dsn", dsn, "-rpc", "127.0.0.1:25123")
+// init registers built-in drivers.
+// In daemon mode, connections are opened by dconn,
+// so all of them are going to the daemon.
+func init() {
+	Register(openDaemon, "sqlite", "sqlite3", "postgres", "postgresql", "mysql")
+}
+
+// openDaemon starts dconn daemon and connects to it.
+// Daemon validates the connection on its side,
+// so we're waiting for it as long as the context allows.
+func openDaemon(ctx context.Context, dsnurl *url.URL) (Database, error) {
+	// Pass the remaining time to the daemon validation
+	args := []string{"-dsn", dsnurl.String(), "-rpc", "127.0.0.1:25123", "-jsonl"}
+	if deadline, ok := ctx.Deadline(); ok {
+		args = append(args, "-connect-timeout", time.Until(deadline).String())
+	}
+	// Start daemon, keeping its errors output
+	stderr := &bytes.Buffer{}
+	cmd := exec.Command("dconn", args...)
+	cmd.Stderr = stderr
 	err := cmd.Start()
 	if err != nil {
 		return nil, err
 	}
+	// Watch for the daemon exit,
+	// it means the connection is failed
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
 	// Wait for daemon to start and open connection
-	var (
-		client *rpc.Client
-		start  = time.Now()
-	)
+	var client *rpc.Client
 	for {
-		// Check timeout
-		if time.Since(start) > 5*time.Second {
-			return nil, err
-		}
 		// Pause
-		time.Sleep(10 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			// Daemon has the same deadline,
+			// so give it a moment to report the actual reason
+			select {
+			case <-exited:
+				return nil, daemonError(stderr.String())
+			case <-time.After(time.Second):
+				cmd.Process.Kill()
+				return nil, errors.Join(ctx.Err(), err)
+			}
+		case <-exited:
+			return nil, daemonError(stderr.String())
+		case <-time.After(10 * time.Millisecond):
+		}
 		// Open connection
 		client, err = rpc.Dial("tcp", "127.0.0.1:25123")
 		if err != nil {
@@ -36,5 +73,27 @@ func Open(dsn string) (Database, error) {
 		break
 	}
 	// Compose and return
-	return &Rpc{client, cmd}, nil
+	return &Rpc{Client: client, Cmd: cmd, Scheme: dsnurl.Scheme}, nil
+}
+
+// daemonError restores the error, reported by the failed daemon (JSON lines).
+// Connection errors are typed by their message prefix,
+// so they can be checked with errors.Is on this side as well.
+func daemonError(output string) error {
+	// Take the last reported error
+	msg := "daemon exited unexpectedly"
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		obj := map[string]any{}
+		if json.Unmarshal([]byte(line), &obj) == nil {
+			if errmsg, ok := obj["error"].(string); ok {
+				msg = errmsg
+			}
+		}
+	}
+	for _, kind := range []error{ErrAuth, ErrNetwork, ErrDatabaseMissing} {
+		if cause, ok := strings.CutPrefix(msg, kind.Error()+": "); ok {
+			return connError(kind, errors.New(cause))
+		}
+	}
+	return errors.New(msg)
 }
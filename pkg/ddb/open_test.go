@@ -0,0 +1,56 @@
+//go:build !daemon
+
+package ddb
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSqlitePath(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"sqlite:///abs/app.db", "/abs/app.db"},
+		{"sqlite://rel/app.db", "rel/app.db"},
+		{"sqlite://app.db?mode=ro", "app.db"},
+		{"sqlite:app.db", "app.db"},
+		{"sqlite:rel/app.db?immutable=1", "rel/app.db"},
+		{"sqlite:my%20app.db", "my app.db"},
+	}
+	for _, tt := range tests {
+		dsnurl, err := url.Parse(tt.dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sqlitePath(dsnurl); got != tt.want {
+			t.Errorf("sqlitePath(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
+
+func TestOpenSqliteOpaque(t *testing.T) {
+	// Relative opaque path must point to the file,
+	// instead of opening a temporary database
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	db, err := OpenContext(context.Background(), "sqlite:app.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.(*Sqlite).Close()
+	if _, err := db.Execute("CREATE TABLE t (id INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app.db")); err != nil {
+		t.Fatalf("database file is not created: %v", err)
+	}
+}
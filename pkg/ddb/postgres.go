@@ -3,10 +3,14 @@
 package ddb
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"go.kyoto.codes/zen/v3/slice"
 )
 
@@ -18,18 +22,63 @@ func (p *Postgres) systemSchemas() []string {
 	return []string{"pg_catalog", "information_schema"}
 }
 
+// PingContext validates the connection,
+// reporting authentication and missing database failures as typed errors.
+func (p *Postgres) PingContext(ctx context.Context) error {
+	err := p.DB.PingContext(ctx)
+	var pgerr *pgconn.PgError
+	if !errors.As(err, &pgerr) {
+		return err
+	}
+	switch {
+	case strings.HasPrefix(pgerr.Code, "28"): // invalid_authorization_specification, invalid_password
+		return connError(ErrAuth, err)
+	case pgerr.Code == "3D000": // invalid_catalog_name
+		return connError(ErrDatabaseMissing, err)
+	case pgerr.Code == "57P03": // cannot_connect_now, server is starting up
+		return connError(ErrNetwork, err)
+	}
+	return err
+}
+
 func (p *Postgres) QueryTables() ([]Table, error) {
+	return p.QueryTablesContext(context.Background())
+}
+
+func (p *Postgres) QueryTablesContext(ctx context.Context) ([]Table, error) {
 	// Query the database for the tables
-	data, err := p.QueryData("SELECT table_name,table_schema FROM information_schema.tables")
+	// Table kind is resolved from pg_class.relkind,
+	// partitioned tables are treated as regular ones.
+	data, err := p.QueryDataContext(ctx, `
+		SELECT
+			c.relname,
+			n.nspname,
+			CASE c.relkind
+				WHEN 'v' THEN 'view'
+				WHEN 'm' THEN 'materialized view'
+				WHEN 'f' THEN 'foreign table'
+				WHEN 'S' THEN 'sequence'
+				ELSE 'table'
+			END AS kind,
+			CASE WHEN c.relkind IN ('v', 'm') THEN pg_get_viewdef(c.oid, true) END AS definition
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p', 'v', 'm', 'f', 'S')
+		ORDER BY n.nspname, c.relname`)
 	if err != nil {
 		return nil, err
 	}
 	// Convert the data to a slice of Table objects
 	tables := slice.Map(data.Rows, func(r []any) Table {
-		return Table{
+		t := Table{
 			Name:   r[0].(string),
 			Schema: r[1].(string),
+			Kind:   TableKind(r[2].(string)),
 		}
+		if r[3] != nil {
+			t.Definition = strings.TrimSuffix(strings.TrimSpace(r[3].(string)), ";")
+		}
+		return t
 	})
 	// Mark system tables
 	tables = slice.Map(tables, func(t Table) Table {
@@ -42,88 +91,357 @@ func (p *Postgres) QueryTables() ([]Table, error) {
 	return tables, nil
 }
 
-func (p *Postgres) QueryColumns(table string) ([]Column, error) {
+func (p *Postgres) QueryColumns(table TableIdent) ([]Column, error) {
+	return p.QueryColumnsContext(context.Background(), table)
+}
+
+// resolveTable resolves the schema of the unqualified table
+// the same way postgres does, following the search_path.
+func (p *Postgres) resolveTable(ctx context.Context, table TableIdent) (TableIdent, error) {
+	// Nothing to resolve
+	if table.Schema != "" {
+		return table, nil
+	}
+	// Query the database for the table schema
+	data, err := p.QueryDataContext(ctx, `
+		SELECT n.nspname
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.oid = to_regclass($1)`, p.QuoteIdent(table.Name))
+	if err != nil {
+		return table, err
+	}
+	if len(data.Rows) == 0 {
+		return table, fmt.Errorf("table %s not found", table)
+	}
+	table.Schema = data.Rows[0][0].(string)
+	return table, nil
+}
+
+func (p *Postgres) QueryColumnsContext(ctx context.Context, table TableIdent) ([]Column, error) {
+	// Resolve table schema, if not provided
+	table, err := p.resolveTable(ctx, table)
+	if err != nil {
+		return nil, err
+	}
 	// Query the database for the columns
-	dataCols, err := p.QueryData(fmt.Sprintf(`
+	dataCols, err := p.QueryDataContext(ctx, `
 		SELECT
 			column_name,
 			data_type,
 			(CASE WHEN is_nullable = 'YES' THEN true ELSE false END) AS is_nullable,
 			column_default
 		FROM information_schema.columns
-		WHERE table_name = '%s'`, table))
+		WHERE table_schema = $1 AND table_name = $2
+		ORDER BY ordinal_position`, table.Schema, table.Name)
 	if err != nil {
 		return nil, err
 	}
 	// Query the database for constraints
-	dataCons, err := p.QueryData(fmt.Sprintf(`
-		SELECT DISTINCT
-		    tc.constraint_name,
-		    tc.constraint_type,
-		    tc.table_name AS referencing_table,
-		    kcu.column_name AS referencing_column,
-		    ccu.table_name AS referenced_table,
-		    ccu.column_name AS referenced_column,
-			fk.update_rule AS foreign_on_update,
-		    fk.delete_rule AS foreign_on_delete
-		FROM
-		    information_schema.table_constraints AS tc
-		    JOIN information_schema.key_column_usage AS kcu
-		      ON tc.constraint_name = kcu.constraint_name
-		      AND tc.table_schema = kcu.table_schema
-		    JOIN information_schema.constraint_column_usage AS ccu
-		      ON ccu.constraint_name = tc.constraint_name
-		      AND ccu.table_schema = tc.table_schema
-			LEFT JOIN information_schema.referential_constraints AS fk
-			  ON fk.constraint_name = tc.constraint_name
-		WHERE
-		     tc.table_name = '%s';
-		`, table))
+	constraints, err := p.QueryConstraintsContext(ctx, table)
 	if err != nil {
 		return nil, err
 	}
+	primary := primaryColumns(constraints)
 	// Compose the columns
 	columns := slice.Map(dataCols.Rows, func(r []any) Column {
-		// Compose base column
-		col := Column{
+		return Column{
 			Name:       r[0].(string),
 			Type:       r[1].(string),
+			IsPrimary:  slice.Contains(primary, r[0].(string)),
 			IsNullable: r[2].(bool),
 			Default:    r[3],
 		}
-		// Find constraints information
-		for _, con := range dataCons.Rows {
-			if con[2].(string) == table && con[3].(string) == col.Name {
-				if con[1].(string) == "PRIMARY KEY" {
-					col.IsPrimary = true
-				}
-				if con[1].(string) == "FOREIGN KEY" {
-					col.ForeignRef = fmt.Sprintf("%s(%s)", con[4].(string), con[5].(string))
-					col.ForeignOnUpdate = con[6].(string)
-					col.ForeignOnDelete = con[7].(string)
-				}
-			}
-		}
-		// Compose constraints
-		return col
 	})
 	// Return
 	return columns, nil
 }
 
+func (p *Postgres) QueryConstraints(table TableIdent) ([]Constraint, error) {
+	return p.QueryConstraintsContext(context.Background(), table)
+}
+
+func (p *Postgres) QueryConstraintsContext(ctx context.Context, table TableIdent) ([]Constraint, error) {
+	// Query the database for the constraints.
+	// We're using pg_constraint directly, because information_schema
+	// doesn't match composite foreign key columns reliably.
+	// Column lists are aggregated into a single string (separated with unit separator),
+	// keeping the key order.
+	data, err := p.QueryDataContext(ctx, `
+		SELECT
+			c.conname,
+			CASE c.contype
+				WHEN 'p' THEN 'PRIMARY KEY'
+				WHEN 'f' THEN 'FOREIGN KEY'
+				WHEN 'u' THEN 'UNIQUE'
+				ELSE 'CHECK'
+			END AS type,
+			(
+				SELECT string_agg(a.attname::text, E'\x1f' ORDER BY k.n)
+				FROM unnest(c.conkey) WITH ORDINALITY AS k(attnum, n)
+				JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+			) AS columns,
+			fn.nspname AS foreign_schema,
+			fc.relname AS foreign_table,
+			(
+				SELECT string_agg(a.attname::text, E'\x1f' ORDER BY k.n)
+				FROM unnest(c.confkey) WITH ORDINALITY AS k(attnum, n)
+				JOIN pg_attribute a ON a.attrelid = c.confrelid AND a.attnum = k.attnum
+			) AS foreign_columns,
+			CASE WHEN c.contype = 'f' THEN
+				CASE c.confupdtype
+					WHEN 'r' THEN 'RESTRICT'
+					WHEN 'c' THEN 'CASCADE'
+					WHEN 'n' THEN 'SET NULL'
+					WHEN 'd' THEN 'SET DEFAULT'
+					ELSE 'NO ACTION'
+				END
+			END AS on_update,
+			CASE WHEN c.contype = 'f' THEN
+				CASE c.confdeltype
+					WHEN 'r' THEN 'RESTRICT'
+					WHEN 'c' THEN 'CASCADE'
+					WHEN 'n' THEN 'SET NULL'
+					WHEN 'd' THEN 'SET DEFAULT'
+					ELSE 'NO ACTION'
+				END
+			END AS on_delete,
+			pg_get_expr(c.conbin, c.conrelid, true) AS check_expr
+		FROM pg_constraint c
+		LEFT JOIN pg_class fc ON fc.oid = c.confrelid
+		LEFT JOIN pg_namespace fn ON fn.oid = fc.relnamespace
+		WHERE c.conrelid = to_regclass($1) AND c.contype IN ('p', 'f', 'u', 'c')
+		ORDER BY c.contype, c.conname`, table.Quoted(p.QuoteIdent))
+	if err != nil {
+		return nil, err
+	}
+	// Convert the data to a slice of Constraint objects
+	str := func(v any) string {
+		if v == nil {
+			return ""
+		}
+		return v.(string)
+	}
+	list := func(v any) []string {
+		if v == nil {
+			return nil
+		}
+		return strings.Split(v.(string), "\x1f")
+	}
+	constraints := slice.Map(data.Rows, func(r []any) Constraint {
+		return Constraint{
+			Name:           r[0].(string),
+			Type:           ConstraintType(r[1].(string)),
+			Columns:        list(r[2]),
+			ForeignTable:   TableIdent{Schema: str(r[3]), Name: str(r[4])},
+			ForeignColumns: list(r[5]),
+			OnUpdate:       str(r[6]),
+			OnDelete:       str(r[7]),
+			Check:          str(r[8]),
+		}
+	})
+	// Return
+	return constraints, nil
+}
+
+func (p *Postgres) QueryTableStats() ([]TableStats, error) {
+	return p.QueryTableStatsContext(context.Background())
+}
+
+func (p *Postgres) QueryTableStatsContext(ctx context.Context) ([]TableStats, error) {
+	// Query the database for the tables statistics.
+	// Row count is an estimation from the last analyze,
+	// it's -1 (since pg14) or 0 if the table was never analyzed.
+	// Manual and automatic analyze/vacuum are treated the same way.
+	data, err := p.QueryDataContext(ctx, `
+		SELECT
+			n.nspname,
+			c.relname,
+			GREATEST(c.reltuples, 0)::bigint AS rows,
+			pg_total_relation_size(c.oid) AS total_size,
+			pg_indexes_size(c.oid) AS index_size,
+			GREATEST(s.last_analyze, s.last_autoanalyze) AS last_analyze,
+			GREATEST(s.last_vacuum, s.last_autovacuum) AS last_vacuum
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_stat_all_tables s ON s.relid = c.oid
+		WHERE c.relkind IN ('r', 'p', 'm')`)
+	if err != nil {
+		return nil, err
+	}
+	// Convert the data to a slice of TableStats objects
+	tm := func(v any) time.Time {
+		if v == nil {
+			return time.Time{}
+		}
+		return v.(time.Time)
+	}
+	stats := slice.Map(data.Rows, func(r []any) TableStats {
+		return TableStats{
+			Schema:      r[0].(string),
+			Name:        r[1].(string),
+			Rows:        r[2].(int64),
+			TotalSize:   r[3].(int64),
+			IndexSize:   r[4].(int64),
+			LastAnalyze: tm(r[5]),
+			LastVacuum:  tm(r[6]),
+		}
+	})
+	// Return
+	return stats, nil
+}
+
+func (p *Postgres) QueryDatabases() ([]DatabaseInfo, error) {
+	return p.QueryDatabasesContext(context.Background())
+}
+
+func (p *Postgres) QueryDatabasesContext(ctx context.Context) ([]DatabaseInfo, error) {
+	// Query the server for the databases, excluding templates.
+	// Size is available only for databases we can connect to.
+	data, err := p.QueryDataContext(ctx, `
+		SELECT
+			datname,
+			pg_get_userbyid(datdba),
+			pg_encoding_to_char(encoding),
+			CASE WHEN has_database_privilege(datname, 'CONNECT') THEN pg_database_size(datname) END,
+			datname = current_database()
+		FROM pg_database
+		WHERE NOT datistemplate
+		ORDER BY datname`)
+	if err != nil {
+		return nil, err
+	}
+	// Convert the data to a slice of DatabaseInfo objects
+	def := func(v any, def any) any {
+		if v == nil {
+			return def
+		}
+		return v
+	}
+	databases := slice.Map(data.Rows, func(r []any) DatabaseInfo {
+		return DatabaseInfo{
+			Name:      r[0].(string),
+			Owner:     def(r[1], "").(string),
+			Encoding:  def(r[2], "").(string),
+			Size:      def(r[3], int64(0)).(int64),
+			IsCurrent: def(r[4], false).(bool),
+		}
+	})
+	// Return
+	return databases, nil
+}
+
+func (p *Postgres) QueryIndexes(table TableIdent) ([]Index, error) {
+	return p.QueryIndexesContext(context.Background(), table)
+}
+
+func (p *Postgres) QueryIndexesContext(ctx context.Context, table TableIdent) ([]Index, error) {
+	// Query the database for the indexes.
+	// Table is resolved with to_regclass, so unqualified name follows the search_path.
+	// Index columns are aggregated into a single string (separated with unit separator),
+	// expressions are wrapped in parentheses to distinguish them from column names.
+	// Key columns go first (indnkeyatts), the rest are INCLUDE columns.
+	// Column options are composed from the non-default operator class and indoption flags
+	// (1 is DESC, 2 is NULLS FIRST, which is the default for DESC).
+	data, err := p.QueryDataContext(ctx, `
+		SELECT
+			i.relname,
+			(
+				SELECT string_agg(
+					CASE WHEN x.indkey[k - 1] = 0
+						THEN '(' || pg_get_indexdef(x.indexrelid, k, true) || ')'
+						ELSE (SELECT a.attname::text FROM pg_attribute a WHERE a.attrelid = x.indrelid AND a.attnum = x.indkey[k - 1])
+					END,
+					E'\x1f' ORDER BY k
+				)
+				FROM generate_series(1, x.indnkeyatts) AS k
+			) AS columns,
+			x.indisunique,
+			x.indisprimary,
+			pg_get_expr(x.indpred, x.indrelid, true) AS predicate,
+			am.amname,
+			(
+				SELECT string_agg(a.attname::text, E'\x1f' ORDER BY k)
+				FROM generate_series(x.indnkeyatts + 1, x.indnatts) AS k
+				JOIN pg_attribute a ON a.attrelid = x.indrelid AND a.attnum = x.indkey[k - 1]
+			) AS include,
+			(
+				SELECT string_agg(
+					concat_ws(' ',
+						CASE WHEN NOT oc.opcdefault THEN quote_ident(oc.opcname) END,
+						CASE WHEN x.indoption[k - 1] & 1 = 1 THEN 'DESC' END,
+						CASE
+							WHEN x.indoption[k - 1] & 3 = 1 THEN 'NULLS LAST'
+							WHEN x.indoption[k - 1] & 3 = 2 THEN 'NULLS FIRST'
+						END
+					),
+					E'\x1f' ORDER BY k
+				)
+				FROM generate_series(1, x.indnkeyatts) AS k
+				JOIN pg_opclass oc ON oc.oid = x.indclass[k - 1]
+			) AS options
+		FROM pg_index x
+		JOIN pg_class i ON i.oid = x.indexrelid
+		JOIN pg_am am ON am.oid = i.relam
+		WHERE x.indrelid = to_regclass($1)
+		ORDER BY i.relname`, table.Quoted(p.QuoteIdent))
+	if err != nil {
+		return nil, err
+	}
+	// Convert the data to a slice of Index objects
+	def := func(v any, def any) any {
+		if v == nil {
+			return def
+		}
+		return v
+	}
+	split := func(v any) []string {
+		if v == nil {
+			return nil
+		}
+		return strings.Split(v.(string), "\x1f")
+	}
+	indexes := slice.Map(data.Rows, func(r []any) Index {
+		return Index{
+			Name:      r[0].(string),
+			Columns:   split(r[1]),
+			IsUnique:  r[2].(bool),
+			IsPrimary: r[3].(bool),
+			Predicate: def(r[4], "").(string),
+			Method:    r[5].(string),
+			Include:   split(r[6]),
+			Options:   split(r[7]),
+		}
+	})
+	// Return
+	return indexes, nil
+}
+
 func (p *Postgres) QueryProcesses() ([]Process, error) {
-	// Query the database for the currently running processes
+	return p.QueryProcessesContext(context.Background())
+}
+
+func (p *Postgres) QueryProcessesContext(ctx context.Context) ([]Process, error) {
+	// Query the database for the currently running processes.
+	// Blocking pids are joined into a string,
+	// because arrays are not supported by the generic data conversion.
 	query := `
 		SELECT
 			pid,
 			date_part('epoch', now() - pg_stat_activity.query_start) AS duration,
 			usename,
 			datname,
-			query
+			query,
+			state,
+			CASE WHEN client_addr IS NOT NULL THEN host(client_addr) || ':' || client_port END AS client,
+			application_name,
+			wait_event_type || ':' || wait_event AS wait_event,
+			xact_start,
+			array_to_string(pg_blocking_pids(pid), ',') AS blocked_by
 		FROM
 			pg_stat_activity
 	`
-	data, err := p.QueryData(query)
+	data, err := p.QueryDataContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -137,11 +455,22 @@ func (p *Postgres) QueryProcesses() ([]Process, error) {
 	}
 	processes := slice.Map(data.Rows, func(r []any) Process {
 		return Process{
-			Pid:      int(def(r[0], 0).(int64)),
-			Duration: time.Duration(def(r[1], 0.0).(float64)) * time.Second,
-			Username: def(r[2], "").(string),
-			Database: def(r[3], "").(string),
-			Query:    strings.Join(strings.Fields(def(r[4], "").(string)), " "),
+			Pid:         int(def(r[0], 0).(int64)),
+			Duration:    time.Duration(def(r[1], 0.0).(float64)) * time.Second,
+			Username:    def(r[2], "").(string),
+			Database:    def(r[3], "").(string),
+			Query:       strings.Join(strings.Fields(def(r[4], "").(string)), " "),
+			State:       def(r[5], "").(string),
+			Client:      def(r[6], "").(string),
+			Application: def(r[7], "").(string),
+			WaitEvent:   def(r[8], "").(string),
+			XactStart:   def(r[9], time.Time{}).(time.Time),
+			BlockedBy: slice.Map(strings.FieldsFunc(def(r[10], "").(string), func(r rune) bool {
+				return r == ','
+			}), func(pid string) int {
+				i, _ := strconv.Atoi(pid)
+				return i
+			}),
 		}
 	})
 
@@ -150,11 +479,196 @@ func (p *Postgres) QueryProcesses() ([]Process, error) {
 }
 
 func (p *Postgres) KillProcess(pid int, force bool) error {
+	return p.KillProcessContext(context.Background(), pid, force)
+}
+
+func (p *Postgres) QueryLocks() ([]Lock, error) {
+	return p.QueryLocksContext(context.Background())
+}
+
+func (p *Postgres) QueryLocksContext(ctx context.Context) ([]Lock, error) {
+	// Query the database for the locks, joined with the holding processes.
+	// Our own locks (taken by this query) are excluded.
+	// Awaited locks go first.
+	query := `
+		SELECT
+			l.pid,
+			n.nspname,
+			c.relname,
+			l.locktype,
+			l.mode,
+			l.granted,
+			a.usename,
+			date_part('epoch', now() - a.query_start) AS duration,
+			a.query
+		FROM
+			pg_locks l
+			LEFT JOIN pg_class c ON c.oid = l.relation
+			LEFT JOIN pg_namespace n ON n.oid = c.relnamespace
+			LEFT JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE
+			l.pid IS DISTINCT FROM pg_backend_pid()
+		ORDER BY
+			l.granted, l.pid
+	`
+	data, err := p.QueryDataContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert the data to a slice of Lock objects
+	def := func(v any, def any) any {
+		if v == nil {
+			return def
+		}
+		return v
+	}
+	locks := slice.Map(data.Rows, func(r []any) Lock {
+		return Lock{
+			Pid:      int(def(r[0], int64(0)).(int64)),
+			Schema:   def(r[1], "").(string),
+			Table:    def(r[2], "").(string),
+			Type:     def(r[3], "").(string),
+			Mode:     def(r[4], "").(string),
+			Granted:  def(r[5], false).(bool),
+			Username: def(r[6], "").(string),
+			Duration: time.Duration(def(r[7], 0.0).(float64)) * time.Second,
+			Query:    strings.Join(strings.Fields(def(r[8], "").(string)), " "),
+		}
+	})
+
+	// Return the list of locks
+	return locks, nil
+}
+
+func (p *Postgres) QueryRoles() ([]Role, error) {
+	return p.QueryRolesContext(context.Background())
+}
+
+func (p *Postgres) QueryRolesContext(ctx context.Context) ([]Role, error) {
+	// Query the database for the roles.
+	// Predefined roles (pg_*) are excluded.
+	data, err := p.QueryDataContext(ctx, `
+		SELECT rolname, rolcanlogin, rolsuper, rolinherit
+		FROM pg_roles
+		WHERE rolname NOT LIKE 'pg\_%'
+		ORDER BY rolname`)
+	if err != nil {
+		return nil, err
+	}
+	// Query the role memberships
+	members, err := p.QueryDataContext(ctx, `
+		SELECT m.rolname, g.rolname
+		FROM pg_auth_members a
+		JOIN pg_roles m ON m.oid = a.member
+		JOIN pg_roles g ON g.oid = a.roleid
+		ORDER BY g.rolname`)
+	if err != nil {
+		return nil, err
+	}
+	memberof := map[string][]string{}
+	for _, r := range members.Rows {
+		memberof[r[0].(string)] = append(memberof[r[0].(string)], r[1].(string))
+	}
+	// Convert the data to a slice of Role objects
+	roles := slice.Map(data.Rows, func(r []any) Role {
+		return Role{
+			Name:      r[0].(string),
+			CanLogin:  r[1].(bool),
+			Superuser: r[2].(bool),
+			Inherit:   r[3].(bool),
+			MemberOf:  memberof[r[0].(string)],
+		}
+	})
+	// Return
+	return roles, nil
+}
+
+func (p *Postgres) QueryGrants() ([]Grant, error) {
+	return p.QueryGrantsContext(context.Background())
+}
+
+func (p *Postgres) QueryGrantsContext(ctx context.Context) ([]Grant, error) {
+	// Query the database for the database, schema and table privileges.
+	// We're expanding ACLs from the catalog instead of using information_schema,
+	// because the last one shows only privileges related to the current user.
+	// Missing ACL means default privileges (owner only, plus PUBLIC ones for databases).
+	// Zero grantee means PUBLIC.
+	// Connection is bound to a single database,
+	// so only the current database privileges are reported.
+	data, err := p.QueryDataContext(ctx, `
+		SELECT
+			COALESCE(r.rolname, 'PUBLIC'),
+			'database',
+			'',
+			'',
+			a.privilege_type,
+			a.is_grantable
+		FROM
+			pg_database d
+			CROSS JOIN LATERAL aclexplode(COALESCE(d.datacl, acldefault('d', d.datdba))) a
+			LEFT JOIN pg_roles r ON r.oid = a.grantee
+		WHERE
+			d.datname = current_database()
+		UNION ALL
+		SELECT
+			COALESCE(r.rolname, 'PUBLIC'),
+			'schema',
+			n.nspname,
+			'',
+			a.privilege_type,
+			a.is_grantable
+		FROM
+			pg_namespace n
+			CROSS JOIN LATERAL aclexplode(COALESCE(n.nspacl, acldefault('n', n.nspowner))) a
+			LEFT JOIN pg_roles r ON r.oid = a.grantee
+		WHERE
+			n.nspname <> 'information_schema'
+			AND n.nspname NOT LIKE 'pg\_%'
+		UNION ALL
+		SELECT
+			COALESCE(r.rolname, 'PUBLIC'),
+			'table',
+			n.nspname,
+			c.relname,
+			a.privilege_type,
+			a.is_grantable
+		FROM
+			pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			CROSS JOIN LATERAL aclexplode(COALESCE(c.relacl, acldefault('r', c.relowner))) a
+			LEFT JOIN pg_roles r ON r.oid = a.grantee
+		WHERE
+			c.relkind IN ('r', 'v', 'm', 'f', 'p')
+			AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+			AND n.nspname NOT LIKE 'pg\_toast%'
+		ORDER BY
+			1, 3, 4, 5`)
+	if err != nil {
+		return nil, err
+	}
+	// Convert the data to a slice of Grant objects.
+	// Database and schema privileges don't cascade to the tables in postgres.
+	grants := slice.Map(data.Rows, func(r []any) Grant {
+		return Grant{
+			Grantee:   r[0].(string),
+			Level:     GrantLevel(r[1].(string)),
+			Schema:    r[2].(string),
+			Table:     r[3].(string),
+			Privilege: r[4].(string),
+			Grantable: r[5].(bool),
+		}
+	})
+	// Return
+	return grants, nil
+}
+
+func (p *Postgres) KillProcessContext(ctx context.Context, pid int, force bool) error {
 	if !force {
-		_, err := p.Exec(fmt.Sprintf("SELECT pg_cancel_backend(%d)", pid))
+		_, err := p.ExecContext(ctx, "SELECT pg_cancel_backend($1)", pid)
 		return err
 	} else {
-		_, err := p.Exec(fmt.Sprintf("SELECT pg_terminate_backend(%d)", pid))
+		_, err := p.ExecContext(ctx, "SELECT pg_terminate_backend($1)", pid)
 		return err
 	}
 }
@@ -1,50 +1,378 @@
 package ddb
 
 import (
+	"context"
+	"encoding/gob"
 	"net/rpc"
 	"os/exec"
+	"sync/atomic"
+	"time"
 )
 
+func init() {
+	// Data rows are transferred as []any,
+	// so gob requires concrete value types to be registered.
+	// Basic types are registered by gob itself, but time is not.
+	gob.Register(time.Time{})
+}
+
 type Rpc struct {
 	*rpc.Client
 	*exec.Cmd
+
+	// Scheme of the daemon connection DSN.
+	// We're using it to resolve dialect details locally,
+	// without calling the daemon.
+	Scheme string
+
+	// Last issued call id.
+	// Each call gets its own id,
+	// so we can ask the daemon to cancel it.
+	id atomic.Int64
 }
 
-func (c *Rpc) QueryData(query string) (*Data, error) {
+// rpcCancelTimeout limits waiting for the cancel call,
+// so unresponsive daemon doesn't block the client on interrupt.
+const rpcCancelTimeout = time.Second
+
+// call invokes the daemon method in a context-aware way.
+// If the context is canceled before the reply,
+// we're asking the daemon to cancel the call (and the query with it).
+//
+// On cancellation, reply might be still decoding in the background,
+// so callers must not use it if error is returned.
+func (c *Rpc) call(ctx context.Context, id int64, method string, args any, reply any) error {
+	call := c.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+		cancel := c.Go("Rpc.Cancel", id, nil, make(chan *rpc.Call, 1))
+		select {
+		case <-cancel.Done:
+		case <-time.After(rpcCancelTimeout):
+		}
+		return ctx.Err()
+	}
+}
+
+func (c *Rpc) QueryData(query string, args ...any) (*Data, error) {
+	return c.QueryDataContext(context.Background(), query, args...)
+}
+
+func (c *Rpc) QueryDataContext(ctx context.Context, query string, args ...any) (*Data, error) {
+	id := c.id.Add(1)
 	res := &Data{}
-	err := c.Call("Rpc.QueryData", query, res)
-	return res, err
+	err := c.call(ctx, id, "Rpc.QueryData", struct {
+		Id    int64
+		Query string
+		Args  []any
+	}{id, query, args}, res)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *Rpc) QueryStream(query string, args ...any) (Stream, error) {
+	return c.QueryStreamContext(context.Background(), query, args...)
+}
+
+func (c *Rpc) QueryStreamContext(ctx context.Context, query string, args ...any) (Stream, error) {
+	id := c.id.Add(1)
+	res := &struct {
+		Cols  []string
+		Types []ColumnType
+	}{}
+	err := c.call(ctx, id, "Rpc.QueryStream", struct {
+		Id    int64
+		Query string
+		Args  []any
+	}{id, query, args}, res)
+	if err != nil {
+		return nil, err
+	}
+	return &rpcStream{rpc: c, ctx: ctx, id: id, cols: res.Cols, types: res.Types}, nil
+}
+
+func (c *Rpc) Execute(query string, args ...any) (*Result, error) {
+	return c.ExecuteContext(context.Background(), query, args...)
+}
+
+func (c *Rpc) ExecuteContext(ctx context.Context, query string, args ...any) (*Result, error) {
+	id := c.id.Add(1)
+	res := &Result{}
+	err := c.call(ctx, id, "Rpc.Execute", struct {
+		Id    int64
+		Query string
+		Args  []any
+	}{id, query, args}, res)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *Rpc) QuoteIdent(ident string) string {
+	return quoteIdent(c.Scheme, ident)
+}
+
+func (c *Rpc) SplitStatements(script string) []string {
+	return splitStatements(c.Scheme, script)
+}
+
+func (c *Rpc) Begin() error {
+	return c.BeginContext(context.Background())
+}
+
+// BeginContext starts a transaction on the daemon side.
+// Unlike direct connections, context is applied to the BEGIN call only,
+// daemon keeps the transaction until Commit or Rollback.
+func (c *Rpc) BeginContext(ctx context.Context) error {
+	id := c.id.Add(1)
+	return c.call(ctx, id, "Rpc.Begin", id, nil)
+}
+
+func (c *Rpc) Commit() error {
+	id := c.id.Add(1)
+	return c.call(context.Background(), id, "Rpc.Commit", id, nil)
+}
+
+func (c *Rpc) Rollback() error {
+	id := c.id.Add(1)
+	return c.call(context.Background(), id, "Rpc.Rollback", id, nil)
 }
 
 func (c *Rpc) QueryTables() ([]Table, error) {
+	return c.QueryTablesContext(context.Background())
+}
+
+func (c *Rpc) QueryTablesContext(ctx context.Context) ([]Table, error) {
+	id := c.id.Add(1)
 	res := &[]Table{}
-	err := c.Call("Rpc.QueryTables", "", res)
-	return *res, err
+	err := c.call(ctx, id, "Rpc.QueryTables", id, res)
+	if err != nil {
+		return nil, err
+	}
+	return *res, nil
 }
 
-func (c *Rpc) QueryColumns(table string) ([]Column, error) {
+func (c *Rpc) QueryColumns(table TableIdent) ([]Column, error) {
+	return c.QueryColumnsContext(context.Background(), table)
+}
+
+func (c *Rpc) QueryColumnsContext(ctx context.Context, table TableIdent) ([]Column, error) {
+	id := c.id.Add(1)
 	res := &[]Column{}
-	err := c.Call("Rpc.QueryColumns", table, res)
-	return *res, err
+	err := c.call(ctx, id, "Rpc.QueryColumns", struct {
+		Id    int64
+		Table TableIdent
+	}{id, table}, res)
+	if err != nil {
+		return nil, err
+	}
+	return *res, nil
+}
+
+func (c *Rpc) QueryIndexes(table TableIdent) ([]Index, error) {
+	return c.QueryIndexesContext(context.Background(), table)
+}
+
+func (c *Rpc) QueryIndexesContext(ctx context.Context, table TableIdent) ([]Index, error) {
+	id := c.id.Add(1)
+	res := &[]Index{}
+	err := c.call(ctx, id, "Rpc.QueryIndexes", struct {
+		Id    int64
+		Table TableIdent
+	}{id, table}, res)
+	if err != nil {
+		return nil, err
+	}
+	return *res, nil
+}
+
+func (c *Rpc) QueryConstraints(table TableIdent) ([]Constraint, error) {
+	return c.QueryConstraintsContext(context.Background(), table)
+}
+
+func (c *Rpc) QueryConstraintsContext(ctx context.Context, table TableIdent) ([]Constraint, error) {
+	id := c.id.Add(1)
+	res := &[]Constraint{}
+	err := c.call(ctx, id, "Rpc.QueryConstraints", struct {
+		Id    int64
+		Table TableIdent
+	}{id, table}, res)
+	if err != nil {
+		return nil, err
+	}
+	return *res, nil
+}
+
+func (c *Rpc) QueryTableStats() ([]TableStats, error) {
+	return c.QueryTableStatsContext(context.Background())
+}
+
+func (c *Rpc) QueryTableStatsContext(ctx context.Context) ([]TableStats, error) {
+	id := c.id.Add(1)
+	res := &[]TableStats{}
+	err := c.call(ctx, id, "Rpc.QueryTableStats", id, res)
+	if err != nil {
+		return nil, err
+	}
+	return *res, nil
+}
+
+func (c *Rpc) QueryDatabases() ([]DatabaseInfo, error) {
+	return c.QueryDatabasesContext(context.Background())
+}
+
+func (c *Rpc) QueryDatabasesContext(ctx context.Context) ([]DatabaseInfo, error) {
+	id := c.id.Add(1)
+	res := &[]DatabaseInfo{}
+	err := c.call(ctx, id, "Rpc.QueryDatabases", id, res)
+	if err != nil {
+		return nil, err
+	}
+	return *res, nil
 }
 
 func (c *Rpc) QueryProcesses() ([]Process, error) {
+	return c.QueryProcessesContext(context.Background())
+}
+
+func (c *Rpc) QueryProcessesContext(ctx context.Context) ([]Process, error) {
+	id := c.id.Add(1)
 	res := &[]Process{}
-	err := c.Call("Rpc.QueryProcesses", "", res)
-	return *res, err
+	err := c.call(ctx, id, "Rpc.QueryProcesses", id, res)
+	if err != nil {
+		return nil, err
+	}
+	return *res, nil
 }
 
 func (c *Rpc) KillProcess(pid int, force bool) error {
-	err := c.Call("Rpc.KillProcess", struct {
+	return c.KillProcessContext(context.Background(), pid, force)
+}
+
+func (c *Rpc) KillProcessContext(ctx context.Context, pid int, force bool) error {
+	id := c.id.Add(1)
+	err := c.call(ctx, id, "Rpc.KillProcess", struct {
+		Id    int64
 		Pid   int
 		Force bool
-	}{pid, force}, nil)
+	}{id, pid, force}, nil)
 	return err
 }
 
+func (c *Rpc) QueryLocks() ([]Lock, error) {
+	return c.QueryLocksContext(context.Background())
+}
+
+func (c *Rpc) QueryLocksContext(ctx context.Context) ([]Lock, error) {
+	id := c.id.Add(1)
+	res := &[]Lock{}
+	err := c.call(ctx, id, "Rpc.QueryLocks", id, res)
+	if err != nil {
+		return nil, err
+	}
+	return *res, nil
+}
+
+func (c *Rpc) QueryRoles() ([]Role, error) {
+	return c.QueryRolesContext(context.Background())
+}
+
+func (c *Rpc) QueryRolesContext(ctx context.Context) ([]Role, error) {
+	id := c.id.Add(1)
+	res := &[]Role{}
+	err := c.call(ctx, id, "Rpc.QueryRoles", id, res)
+	if err != nil {
+		return nil, err
+	}
+	return *res, nil
+}
+
+func (c *Rpc) QueryGrants() ([]Grant, error) {
+	return c.QueryGrantsContext(context.Background())
+}
+
+func (c *Rpc) QueryGrantsContext(ctx context.Context) ([]Grant, error) {
+	id := c.id.Add(1)
+	res := &[]Grant{}
+	err := c.call(ctx, id, "Rpc.QueryGrants", id, res)
+	if err != nil {
+		return nil, err
+	}
+	return *res, nil
+}
+
 func (c *Rpc) Close() error {
 	// Close the connection
 	c.Client.Close()
 	// Kill the process
 	return c.Cmd.Process.Kill()
 }
+
+// rpcStream is a Stream implementation on top of the daemon stream.
+// Daemon holds an actual stream and we're pulling it chunk by chunk,
+// so data is not accumulated on either side.
+type rpcStream struct {
+	rpc   *Rpc
+	ctx   context.Context
+	id    int64 // Stream shares the id with the call that opened it
+	cols  []string
+	types []ColumnType
+
+	data *Data
+	err  error
+}
+
+func (s *rpcStream) Cols() []string {
+	return s.cols
+}
+
+func (s *rpcStream) Types() []ColumnType {
+	return s.types
+}
+
+func (s *rpcStream) Next() bool {
+	// Pull the next chunk from the daemon
+	data := &Data{}
+	if s.err = s.rpc.call(s.ctx, s.id, "Rpc.StreamNext", s.id, data); s.err != nil {
+		s.data = nil
+		return false
+	}
+	s.data = data
+	// Empty chunk means there are no more rows
+	return len(s.data.Rows) > 0
+}
+
+func (s *rpcStream) NextResultSet() bool {
+	// Don't proceed after failure
+	if s.err != nil {
+		return false
+	}
+	// Ask the daemon to advance and pull the new set columns
+	res := &struct {
+		Cols  []string
+		Types []ColumnType
+		More  bool
+	}{}
+	if s.err = s.rpc.call(s.ctx, s.id, "Rpc.StreamNextResultSet", s.id, res); s.err != nil || !res.More {
+		return false
+	}
+	s.cols, s.types = res.Cols, res.Types
+	return true
+}
+
+func (s *rpcStream) Data() *Data {
+	return s.data
+}
+
+func (s *rpcStream) Err() error {
+	return s.err
+}
+
+func (s *rpcStream) Close() error {
+	return s.rpc.Call("Rpc.StreamClose", s.id, nil)
+}
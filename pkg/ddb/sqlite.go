@@ -3,91 +3,578 @@
 package ddb
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"unicode"
 
+	"go.kyoto.codes/zen/v3/logic"
 	"go.kyoto.codes/zen/v3/slice"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 type Sqlite struct {
 	Connection
 }
 
+// whereRgx matches the WHERE keyword of the partial index definition.
+// Index definition can't have nested statements,
+// so the last occurrence is the one we need.
+var whereRgx = regexp.MustCompile(`(?is)^.*\bWHERE\b`)
+
+// viewRgx matches the CREATE VIEW statement up to the view query.
+var viewRgx = regexp.MustCompile(`(?is)^\s*CREATE\s+(TEMP\s+|TEMPORARY\s+)?VIEW\s+(IF\s+NOT\s+EXISTS\s+)?("(?:[^"]|"")*"|\S+?)\s*(\([^)]*\))?\s+AS\b`)
+
+// constraintRgx matches the constraint name, preceding the constraint definition.
+var constraintRgx = regexp.MustCompile("(?is)\\bCONSTRAINT\\s+(\"(?:[^\"]|\"\")*\"|`(?:[^`]|``)*`|\\[[^\\]]*\\]|[^\\s\"`\\[]+)\\s*$")
+
 func (s *Sqlite) systemTables() []string {
 	return []string{"sqlite_master", "sqlite_sequence", "sqlite_stat1"}
 }
 
+// pragma composes a query to the table-valued pragma function for the table.
+// SQLite schema is an attached database name,
+// which is passed as a second function argument (if provided).
+func (s *Sqlite) pragma(name string, table TableIdent) (string, []any) {
+	if table.Schema == "" {
+		return fmt.Sprintf("SELECT * FROM %s(?)", name), []any{table.Name}
+	}
+	return fmt.Sprintf("SELECT * FROM %s(?, ?)", name), []any{table.Name, table.Schema}
+}
+
+// PingContext validates the connection,
+// reporting a database file that can't be opened as a typed error.
+func (s *Sqlite) PingContext(ctx context.Context) error {
+	err := s.DB.PingContext(ctx)
+	var sqerr *sqlite.Error
+	if errors.As(err, &sqerr) && sqerr.Code()&0xff == sqlite3.SQLITE_CANTOPEN {
+		return connError(ErrDatabaseMissing, err)
+	}
+	return err
+}
+
 func (s *Sqlite) QueryTables() ([]Table, error) {
-	// Query the database for the tables
-	data, err := s.QueryData("SELECT name,'' FROM sqlite_master WHERE type='table'")
+	return s.QueryTablesContext(context.Background())
+}
+
+// schemas returns names of the databases, attached to the connection
+// (including main and temp ones).
+func (s *Sqlite) schemas(ctx context.Context) ([]string, error) {
+	// Reference column list: seq, name, file
+	data, err := s.QueryDataContext(ctx, "SELECT name FROM pragma_database_list ORDER BY seq")
 	if err != nil {
 		return nil, err
 	}
-	// Convert the data to a slice of Table objects
-	tables := slice.Map(data.Rows, func(r []any) Table {
-		return Table{
-			Name: r[0].(string),
+	return slice.Map(data.Rows, func(r []any) string {
+		return r[0].(string)
+	}), nil
+}
+
+func (s *Sqlite) QueryTablesContext(ctx context.Context) ([]Table, error) {
+	// Query the database for the schemas (attached databases).
+	// Each one has its own sqlite_master.
+	schemas, err := s.schemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tables := []Table{}
+	for _, schema := range schemas {
+		// Query the database for the tables
+		data, err := s.QueryDataContext(ctx, fmt.Sprintf("SELECT name,type,sql FROM %s.sqlite_master WHERE type IN ('table','view')", s.QuoteIdent(schema)))
+		if err != nil {
+			return nil, err
 		}
-	})
-	// SQLite doesn't include system tables into sqlite_master,
+		// Convert the data to a slice of Table objects.
+		// SQLite stores the whole CREATE VIEW statement,
+		// so we have to extract the query from it.
+		// System tables (like sqlite_stat1 after analyze) are added below.
+		rows := slice.Filter(data.Rows, func(r []any) bool {
+			return !slice.Contains(s.systemTables(), r[0].(string))
+		})
+		tables = append(tables, slice.Map(rows, func(r []any) Table {
+			t := Table{
+				Name:   r[0].(string),
+				Schema: schema,
+				Kind:   TableKind(r[1].(string)),
+			}
+			if def, ok := r[2].(string); ok && t.Kind == TableKindView {
+				if loc := viewRgx.FindStringIndex(def); loc != nil {
+					t.Definition = strings.TrimSpace(def[loc[1]:])
+				}
+			}
+			return t
+		})...)
+	}
+	// SQLite doesn't include sqlite_master into itself
+	// (and other system tables appear only once created),
 	// so we have to manually add them.
 	tables = append(
 		tables,
 		slice.Map(s.systemTables(), func(t string) Table {
-			return Table{Name: t, IsSystem: true}
+			return Table{Schema: "main", Name: t, Kind: TableKindTable, IsSystem: true}
 		})...,
 	)
 	// Return
 	return tables, nil
 }
 
-func (s *Sqlite) QueryColumns(table string) ([]Column, error) {
+func (s *Sqlite) QueryColumns(table TableIdent) ([]Column, error) {
+	return s.QueryColumnsContext(context.Background(), table)
+}
+
+func (s *Sqlite) QueryColumnsContext(ctx context.Context, table TableIdent) ([]Column, error) {
 	// Query the database for the columns.
 	// We can't select exact fields because of 'notnull' issue (syntax error near "notnull").
 	// So, here is a reference column list:
 	// cid, name, type, notnull, dflt_value, pk
-	dataCols, err := s.QueryData(fmt.Sprintf("SELECT * FROM PRAGMA_TABLE_INFO('%s')", table))
+	query, args := s.pragma("PRAGMA_TABLE_INFO", table)
+	dataCols, err := s.QueryDataContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
-	// Query the database for the foreign keys information.
-	// Same as above, we can't select exact fields because of syntax error.
-	// So, here is a reference column list:
-	// id, seq, table, from, to, on_update, on_delete, match
-	dataFks, err := s.QueryData(fmt.Sprintf("SELECT * FROM PRAGMA_FOREIGN_KEY_LIST('%s')", table))
+	// Query the database for constraints
+	constraints, err := s.QueryConstraintsContext(ctx, table)
 	if err != nil {
 		return nil, err
 	}
+	primary := primaryColumns(constraints)
 	// Compose the columns
 	columns := slice.Map(dataCols.Rows, func(r []any) Column {
-		// Compose base column
-		col := Column{
+		return Column{
 			Name:       r[1].(string),
 			Type:       r[2].(string),
-			IsPrimary:  r[5].(int64) == 1,
+			IsPrimary:  slice.Contains(primary, r[1].(string)),
 			IsNullable: r[3].(int64) == 0,
 			Default:    r[4],
 		}
-		// Find foreign key information
-		for _, fk := range dataFks.Rows {
-			if fk[3].(string) == col.Name {
-				col.ForeignRef = fmt.Sprintf("%s(%s)", fk[2].(string), fk[4].(string))
-				col.ForeignOnUpdate = fk[5].(string)
-				col.ForeignOnDelete = fk[6].(string)
-				break
+	})
+	// Return
+	return columns, nil
+}
+
+func (s *Sqlite) QueryConstraints(table TableIdent) ([]Constraint, error) {
+	return s.QueryConstraintsContext(context.Background(), table)
+}
+
+func (s *Sqlite) QueryConstraintsContext(ctx context.Context, table TableIdent) ([]Constraint, error) {
+	constraints := []Constraint{}
+	// Query the database for the primary key columns.
+	// Reference column list: cid, name, type, notnull, dflt_value, pk.
+	// pk is a position of the column in the primary key (starting from 1), or 0.
+	query, args := s.pragma("PRAGMA_TABLE_INFO", table)
+	dataCols, err := s.QueryDataContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	pk := Constraint{Type: ConstraintPrimaryKey}
+	for pos := int64(1); ; pos++ {
+		found := false
+		for _, r := range dataCols.Rows {
+			if r[5].(int64) == pos {
+				pk.Columns = append(pk.Columns, r[1].(string))
+				found = true
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	if len(pk.Columns) > 0 {
+		constraints = append(constraints, pk)
+	}
+	// Query the database for the foreign keys information.
+	// Reference column list: id, seq, table, from, to, on_update, on_delete, match.
+	// Composite keys are represented with multiple rows with the same id.
+	// Referenced column is empty if the key references the primary key implicitly.
+	// Referenced table is always resolved within the same schema,
+	// SQLite doesn't allow to qualify it.
+	query, args = s.pragma("PRAGMA_FOREIGN_KEY_LIST", table)
+	dataFks, err := s.QueryDataContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	fks := map[int64]int{} // Foreign key id to constraints index
+	for _, r := range dataFks.Rows {
+		i, ok := fks[r[0].(int64)]
+		if !ok {
+			i = len(constraints)
+			fks[r[0].(int64)] = i
+			constraints = append(constraints, Constraint{
+				Type:         ConstraintForeignKey,
+				ForeignTable: TableIdent{Name: r[2].(string)},
+				OnUpdate:     r[5].(string),
+				OnDelete:     r[6].(string),
+			})
+		}
+		constraints[i].Columns = append(constraints[i].Columns, r[3].(string))
+		if to, ok := r[4].(string); ok {
+			constraints[i].ForeignColumns = append(constraints[i].ForeignColumns, to)
+		}
+	}
+	// Unique constraints are backed by automatic indexes,
+	// so we're taking them from the indexes list.
+	indexes, err := s.QueryIndexesContext(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range indexes {
+		if strings.HasPrefix(index.Name, "sqlite_autoindex_") && !index.IsPrimary {
+			constraints = append(constraints, Constraint{Type: ConstraintUnique, Columns: index.Columns})
+		}
+	}
+	// SQLite doesn't provide check constraints at all,
+	// so we have to extract them from the table definition.
+	master := "sqlite_master"
+	if table.Schema != "" {
+		master = s.QuoteIdent(table.Schema) + "." + master
+	}
+	dataDef, err := s.QueryDataContext(ctx, fmt.Sprintf("SELECT sql FROM %s WHERE type='table' AND name=?", master), table.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(dataDef.Rows) > 0 && dataDef.Rows[0][0] != nil {
+		constraints = append(constraints, tableChecks(dataDef.Rows[0][0].(string))...)
+	}
+	// Return
+	return constraints, nil
+}
+
+// tableChecks extracts check constraints from the table definition.
+// Quoted identifiers and literals are skipped,
+// so the CHECK word inside of them is not matched.
+func tableChecks(def string) []Constraint {
+	checks := []Constraint{}
+	isword := func(b byte) bool {
+		return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+	}
+	for i := 0; i < len(def); i++ {
+		// Skip quoted parts
+		if end := quotedEnd(def, i); end != i {
+			i = end - 1
+			continue
+		}
+		// Match the CHECK keyword
+		if i+5 > len(def) || !strings.EqualFold(def[i:i+5], "CHECK") ||
+			(i > 0 && isword(def[i-1])) || (i+5 < len(def) && isword(def[i+5])) {
+			continue
+		}
+		// Find the expression boundaries
+		open := i + 5
+		for open < len(def) && unicode.IsSpace(rune(def[open])) {
+			open++
+		}
+		if open >= len(def) || def[open] != '(' {
+			continue
+		}
+		end := closingParen(def, open)
+		if end == -1 {
+			break
+		}
+		// Compose the constraint.
+		// Name is provided with CONSTRAINT clause, right before the keyword.
+		check := Constraint{Type: ConstraintCheck, Check: strings.TrimSpace(def[open+1 : end])}
+		if m := constraintRgx.FindStringSubmatch(def[:i]); m != nil {
+			check.Name = unquoteIdent(m[1])
+		}
+		checks = append(checks, check)
+		i = end
+	}
+	return checks
+}
+
+// quotedEnd returns the end of the quoted identifier or literal, starting at i
+// (index right after the closing quote).
+// If there is no quote at i, i is returned.
+func quotedEnd(def string, i int) int {
+	quote := def[i]
+	switch quote {
+	case '"', '`', '\'':
+	case '[':
+		quote = ']'
+	default:
+		return i
+	}
+	// Doubled quote is handled naturally,
+	// as it closes and re-opens the quote.
+	end := strings.IndexByte(def[i+1:], quote)
+	if end == -1 {
+		return len(def)
+	}
+	return i + 1 + end + 1
+}
+
+// closingParen returns the index of the parenthesis,
+// closing the one at open index, or -1 if it's not closed.
+func closingParen(def string, open int) int {
+	depth := 0
+	for i := open; i < len(def); i++ {
+		if end := quotedEnd(def, i); end != i {
+			i = end - 1
+			continue
+		}
+		switch def[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
 			}
 		}
-		// Return
-		return col
+	}
+	return -1
+}
+
+func (s *Sqlite) QueryTableStats() ([]TableStats, error) {
+	return s.QueryTableStatsContext(context.Background())
+}
+
+func (s *Sqlite) QueryTableStatsContext(ctx context.Context) ([]TableStats, error) {
+	// Query the database for the tables
+	tables, err := s.QueryTablesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tables = slice.Filter(tables, func(t Table) bool {
+		return t.Kind == TableKindTable && !t.IsSystem
 	})
+	// Stats are stored separately in each schema (attached database)
+	schemas := []string{}
+	for _, t := range tables {
+		if !slice.Contains(schemas, t.Schema) {
+			schemas = append(schemas, t.Schema)
+		}
+	}
+	sizes := map[TableIdent][2]int64{} // Table to total and index sizes
+	estimates := map[TableIdent]int64{}
+	for _, schema := range schemas {
+		// Query the database for the pages, used by tables and their indexes.
+		// dbstat virtual table might be not compiled in,
+		// in that case sizes are left empty.
+		if data, err := s.QueryDataContext(ctx, fmt.Sprintf(`
+			SELECT
+				m.tbl_name,
+				SUM(d.pgsize),
+				SUM(CASE WHEN m.type = 'index' THEN d.pgsize ELSE 0 END)
+			FROM dbstat(?) AS d
+			JOIN %s.sqlite_master AS m ON m.name = d.name
+			GROUP BY m.tbl_name`, s.QuoteIdent(schema)), schema); err == nil {
+			for _, r := range data.Rows {
+				sizes[TableIdent{Schema: schema, Name: r[0].(string)}] = [2]int64{r[1].(int64), r[2].(int64)}
+			}
+		}
+		// Query the database for the row estimations.
+		// sqlite_stat1 is created and filled on analyze only,
+		// each stat starts with a table row count (so casting takes it).
+		if data, err := s.QueryDataContext(ctx, fmt.Sprintf("SELECT tbl, MAX(CAST(stat AS INTEGER)) FROM %s.sqlite_stat1 GROUP BY tbl", s.QuoteIdent(schema))); err == nil {
+			for _, r := range data.Rows {
+				estimates[TableIdent{Schema: schema, Name: r[0].(string)}] = r[1].(int64)
+			}
+		}
+	}
+	// Compose the stats.
+	// Tables, not covered by analyze, are left without row count,
+	// counting them directly means a full scan of each table.
+	stats := []TableStats{}
+	for _, t := range tables {
+		stats = append(stats, TableStats{
+			Schema:    t.Schema,
+			Name:      t.Name,
+			Rows:      estimates[t.Ident()],
+			TotalSize: sizes[t.Ident()][0],
+			IndexSize: sizes[t.Ident()][1],
+		})
+	}
 	// Return
-	return columns, nil
+	return stats, nil
+}
+
+func (s *Sqlite) QueryDatabases() ([]DatabaseInfo, error) {
+	return s.QueryDatabasesContext(context.Background())
+}
+
+func (s *Sqlite) QueryDatabasesContext(ctx context.Context) ([]DatabaseInfo, error) {
+	return nil, errors.New("sqlite doesn't support databases listing, database is a file (attached ones are listed as schemas)")
+}
+
+func (s *Sqlite) QueryIndexes(table TableIdent) ([]Index, error) {
+	return s.QueryIndexesContext(context.Background(), table)
+}
+
+func (s *Sqlite) QueryIndexesContext(ctx context.Context, table TableIdent) ([]Index, error) {
+	// Query the database for the indexes.
+	// Same as for columns, here is a reference column list:
+	// seq, name, unique, origin, partial
+	query, args := s.pragma("PRAGMA_INDEX_LIST", table)
+	dataIdx, err := s.QueryDataContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	// Compose the indexes
+	indexes := []Index{}
+	for _, r := range dataIdx.Rows {
+		index := Index{
+			Name:      r[1].(string),
+			IsUnique:  r[2].(int64) == 1,
+			IsPrimary: r[3].(string) == "pk",
+			Method:    "btree", // SQLite has only b-tree indexes
+		}
+		// Query the database for the index columns.
+		// Reference column list: seqno, cid, name, desc, coll, key.
+		// Name is empty for expressions, non-key columns (rowid) are skipped.
+		query, args := s.pragma("PRAGMA_INDEX_XINFO", TableIdent{Schema: table.Schema, Name: index.Name})
+		dataCols, err := s.QueryDataContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		keys := slice.Filter(dataCols.Rows, func(r []any) bool {
+			return r[5].(int64) == 1
+		})
+		index.Columns = slice.Map(keys, func(r []any) string {
+			if r[2] == nil {
+				return ""
+			}
+			return r[2].(string)
+		})
+		index.Options = slice.Map(keys, func(r []any) string {
+			return logic.Tr(r[3].(int64) == 1, "DESC", "")
+		})
+		// SQLite doesn't provide expressions and partial index condition directly,
+		// so we have to extract them from the index definition.
+		if r[4].(int64) == 1 || slice.Contains(index.Columns, "") {
+			def, err := s.indexDefinition(ctx, table.Schema, index.Name)
+			if err != nil {
+				return nil, err
+			}
+			parts := indexParts(def)
+			for i := range index.Columns {
+				if index.Columns[i] != "" {
+					continue
+				}
+				index.Columns[i] = "(expression)"
+				if i < len(parts) {
+					index.Columns[i] = "(" + parts[i] + ")"
+				}
+			}
+			if loc := whereRgx.FindStringIndex(def); r[4].(int64) == 1 && loc != nil {
+				index.Predicate = strings.TrimSpace(def[loc[1]:])
+			}
+		}
+		indexes = append(indexes, index)
+	}
+	// Return
+	return indexes, nil
+}
+
+// indexDefinition returns the index definition (CREATE INDEX statement),
+// stored in sqlite_master.
+func (s *Sqlite) indexDefinition(ctx context.Context, schema string, index string) (string, error) {
+	master := "sqlite_master"
+	if schema != "" {
+		master = s.QuoteIdent(schema) + "." + master
+	}
+	data, err := s.QueryDataContext(ctx, fmt.Sprintf("SELECT sql FROM %s WHERE type='index' AND name=?", master), index)
+	if err != nil || len(data.Rows) == 0 || data.Rows[0][0] == nil {
+		return "", err
+	}
+	return data.Rows[0][0].(string), nil
+}
+
+// indexParts splits the indexed columns list of the index definition
+// into separate parts (column names or expressions), as they're written.
+// It takes the first parenthesized group, skipping quoted identifiers and literals.
+func indexParts(def string) []string {
+	var (
+		parts []string
+		depth int
+		quote rune
+		start int
+	)
+	for i, r := range def {
+		switch {
+		case quote != 0:
+			// Doubled quote is handled naturally,
+			// as it closes and re-opens the quote.
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '`' || r == '\'' || r == '[':
+			quote = logic.Tr(r == '[', ']', r)
+		case r == '(':
+			depth++
+			if depth == 1 {
+				start = i + 1
+			}
+		case r == ',' && depth == 1:
+			parts = append(parts, strings.TrimSpace(def[start:i]))
+			start = i + 1
+		case r == ')':
+			depth--
+			if depth == 0 {
+				return append(parts, strings.TrimSpace(def[start:i]))
+			}
+		}
+	}
+	return parts
 }
 
 func (s *Sqlite) QueryProcesses() ([]Process, error) {
-	return nil, errors.New("sqlite doesn't support process list query, use `lsof <file>` instead")
+	return s.QueryProcessesContext(context.Background())
+}
+
+// QueryProcessesContext lists processes, holding the database file open.
+// SQLite has no server to ask, so it's resolved on the OS level (see sqliteProcesses).
+func (s *Sqlite) QueryProcessesContext(ctx context.Context) ([]Process, error) {
+	return sqliteProcesses(sqlitePath(s.DSN))
 }
 
 func (s *Sqlite) KillProcess(pid int, force bool) error {
-	return errors.New("sqlite doesn't support process killing, use `lsof <file>` + `kill` instead")
+	return s.KillProcessContext(context.Background(), pid, force)
+}
+
+// KillProcessContext sends a signal to the process, holding the database file open.
+// SIGTERM is used for graceful shutdown, and SIGKILL if forced.
+// We're not allowing to signal processes, not related to the database.
+func (s *Sqlite) KillProcessContext(ctx context.Context, pid int, force bool) error {
+	processes, err := s.QueryProcessesContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !slice.Contains(slice.Map(processes, func(p Process) int { return p.Pid }), pid) {
+		return fmt.Errorf("process %d doesn't hold the database file", pid)
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Signal(logic.Tr[os.Signal](force, syscall.SIGKILL, syscall.SIGTERM))
+}
+
+func (s *Sqlite) QueryLocks() ([]Lock, error) {
+	return s.QueryLocksContext(context.Background())
+}
+
+func (s *Sqlite) QueryLocksContext(ctx context.Context) ([]Lock, error) {
+	return nil, errors.New("sqlite doesn't support locks query, database is locked as a whole")
+}
+
+func (s *Sqlite) QueryRoles() ([]Role, error) {
+	return s.QueryRolesContext(context.Background())
+}
+
+func (s *Sqlite) QueryRolesContext(ctx context.Context) ([]Role, error) {
+	return nil, errors.New("sqlite doesn't support roles, access is controlled by file permissions")
+}
+
+func (s *Sqlite) QueryGrants() ([]Grant, error) {
+	return s.QueryGrantsContext(context.Background())
+}
+
+func (s *Sqlite) QueryGrantsContext(ctx context.Context) ([]Grant, error) {
+	return nil, errors.New("sqlite doesn't support grants, access is controlled by file permissions")
 }
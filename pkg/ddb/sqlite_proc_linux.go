@@ -0,0 +1,139 @@
+//go:build !daemon
+
+package ddb
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// clockTicks is a kernel USER_HZ value,
+// used in /proc/<pid>/stat times.
+// It's 100 on all mainstream architectures,
+// and we can't query sysconf without cgo.
+const clockTicks = 100
+
+// sqliteProcesses finds processes, holding the database file open.
+// There is no server to ask, so we're scanning /proc/*/fd
+// for handles on the database file and its -wal/-shm siblings.
+// Processes of other users are visible to root only.
+//
+// Kernel doesn't track when the file was opened,
+// so the process running time is reported as a duration.
+func sqliteProcesses(path string) ([]Process, error) {
+	// Resolve file names we're looking for.
+	// Descriptor links are absolute and resolved,
+	// so we're checking both given and resolved paths.
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{abs}
+	if real, err := filepath.EvalSymlinks(abs); err == nil && real != abs {
+		names = append(names, real)
+	}
+	files := map[string]bool{}
+	for _, name := range names {
+		files[name], files[name+"-wal"], files[name+"-shm"] = true, true, true
+	}
+	// Scan processes descriptors
+	dirs, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	uptime := procUptime()
+	processes := []Process{}
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(dir.Name())
+		// Skip non-process entries and ourselves
+		// (the daemon, when running behind dconn)
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		// Process might be gone or inaccessible, so errors are skipped
+		fds, err := os.ReadDir(filepath.Join("/proc", dir.Name(), "fd"))
+		if err != nil {
+			continue
+		}
+		holds := false
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join("/proc", dir.Name(), "fd", fd.Name()))
+			if err == nil && files[target] {
+				holds = true
+				break
+			}
+		}
+		if !holds {
+			continue
+		}
+		processes = append(processes, procProcess(pid, abs, uptime))
+	}
+	// Directory entries are sorted as strings
+	sort.Slice(processes, func(i, j int) bool {
+		return processes[i].Pid < processes[j].Pid
+	})
+	return processes, nil
+}
+
+// procProcess composes process information from /proc/<pid>.
+// Missing details are left empty.
+func procProcess(pid int, database string, uptime time.Duration) Process {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	process := Process{
+		Pid:      pid,
+		Database: database,
+		State:    "active",
+	}
+	// Process owner is the owner of its /proc directory
+	if info, err := os.Stat(dir); err == nil {
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			uid := strconv.Itoa(int(stat.Uid))
+			process.Username = uid
+			if u, err := user.LookupId(uid); err == nil {
+				process.Username = u.Username
+			}
+		}
+	}
+	// Command line arguments are null-separated
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		process.Query = strings.Join(strings.Fields(strings.ReplaceAll(string(cmdline), "\x00", " ")), " ")
+	}
+	if comm, err := os.ReadFile(filepath.Join(dir, "comm")); err == nil {
+		process.Application = strings.TrimSpace(string(comm))
+	}
+	// Start time is the 22nd field of stat, in clock ticks after boot.
+	// Command name (2nd field) might contain spaces, so we're counting after it.
+	if stat, err := os.ReadFile(filepath.Join(dir, "stat")); err == nil && uptime > 0 {
+		fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+		if len(fields) > 19 {
+			if start, err := strconv.ParseInt(fields[19], 10, 64); err == nil {
+				process.Duration = (uptime - time.Duration(start)*time.Second/clockTicks).Truncate(time.Second)
+			}
+		}
+	}
+	return process
+}
+
+// procUptime returns system uptime,
+// or zero if it can't be resolved.
+func procUptime() time.Duration {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
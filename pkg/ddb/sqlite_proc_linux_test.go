@@ -0,0 +1,44 @@
+//go:build !daemon
+
+package ddb
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestSqliteProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.db")
+	// We're holding the database file ourselves,
+	// and a child process is holding its wal file
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	wal, err := os.Create(path + "-wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Close()
+	cmd := exec.Command("sleep", "10")
+	cmd.Stdin = wal
+	if err := cmd.Start(); err != nil {
+		t.Skip("can't start a child process:", err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	processes, err := sqliteProcesses(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(processes) != 1 || processes[0].Pid != cmd.Process.Pid {
+		t.Fatalf("expected the child process %d only, got %+v", cmd.Process.Pid, processes)
+	}
+	if p := processes[0]; p.Application != "sleep" || p.Database != path {
+		t.Fatalf("unexpected process details %+v", p)
+	}
+}
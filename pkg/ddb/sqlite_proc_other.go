@@ -0,0 +1,10 @@
+//go:build !daemon && !linux
+
+package ddb
+
+import "errors"
+
+// sqliteProcesses is implemented with /proc scan, which is available on Linux only.
+func sqliteProcesses(path string) ([]Process, error) {
+	return nil, errors.New("sqlite process list is supported on linux only, use `lsof <file>` instead")
+}
@@ -0,0 +1,95 @@
+//go:build !daemon
+
+package ddb
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testSqlite opens a temporary sqlite database,
+// initialized with the given statements.
+func testSqlite(t *testing.T, stmts ...string) *Sqlite {
+	t.Helper()
+	db, err := OpenContext(context.Background(), "sqlite://"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.(*Sqlite).Close() })
+	for _, stmt := range stmts {
+		if _, err := db.Execute(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db.(*Sqlite)
+}
+
+func TestSqliteColumnTypes(t *testing.T) {
+	db := testSqlite(t,
+		"CREATE TABLE t (id INTEGER, name VARCHAR(64), data BLOB)",
+		"INSERT INTO t VALUES (1, 'a', X'00')",
+	)
+	data, err := db.QueryData("SELECT id, name, data, 1 + 1 AS expr FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// SQLite reports declared types as-is
+	want := []string{"INTEGER", "VARCHAR(64)", "BLOB", ""}
+	if len(data.Types) != len(want) {
+		t.Fatalf("expected %d column types, got %d", len(want), len(data.Types))
+	}
+	for i, ct := range data.Types {
+		if ct.DatabaseType != want[i] {
+			t.Errorf("column %s: type %q, want %q", data.Cols[i], ct.DatabaseType, want[i])
+		}
+	}
+	if !data.Types[0].IsNumeric() || !data.Types[2].IsBinary() {
+		t.Errorf("unexpected column kinds %+v", data.Types)
+	}
+}
+
+func TestSqliteConstraints(t *testing.T) {
+	db := testSqlite(t,
+		"CREATE TABLE parent (a INTEGER, b INTEGER, PRIMARY KEY (b, a))",
+		`CREATE TABLE child (
+			id INTEGER PRIMARY KEY,
+			pa INTEGER,
+			pb INTEGER,
+			code TEXT UNIQUE,
+			"check" TEXT DEFAULT 'CHECK (x)',
+			qty INTEGER CHECK (qty > 0),
+			FOREIGN KEY (pb, pa) REFERENCES parent (b, a) ON DELETE CASCADE,
+			CONSTRAINT positive CHECK (pa >= 0 AND (pb >= 0))
+		)`,
+	)
+	parent, err := db.QueryConstraints(TableIdent{Name: "parent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parent) != 1 || parent[0].Type != ConstraintPrimaryKey || !reflect.DeepEqual(parent[0].Columns, []string{"b", "a"}) {
+		t.Fatalf("unexpected parent constraints %+v", parent)
+	}
+	child, err := db.QueryConstraints(TableIdent{Name: "child"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Constraint{
+		{Type: ConstraintPrimaryKey, Columns: []string{"id"}},
+		{
+			Type:           ConstraintForeignKey,
+			Columns:        []string{"pb", "pa"},
+			ForeignTable:   TableIdent{Name: "parent"},
+			ForeignColumns: []string{"b", "a"},
+			OnUpdate:       "NO ACTION",
+			OnDelete:       "CASCADE",
+		},
+		{Type: ConstraintUnique, Columns: []string{"code"}},
+		{Type: ConstraintCheck, Check: "qty > 0"},
+		{Type: ConstraintCheck, Name: "positive", Check: "pa >= 0 AND (pb >= 0)"},
+	}
+	if !reflect.DeepEqual(child, want) {
+		t.Fatalf("unexpected child constraints:\n%+v\nwant:\n%+v", child, want)
+	}
+}
@@ -0,0 +1,296 @@
+package ddb
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"go.kyoto.codes/zen/v3/slice"
+)
+
+// execKeywords holds leading keywords of statements
+// that don't return rows (DML/DDL, session and transaction control).
+var execKeywords = []string{
+	"INSERT", "UPDATE", "DELETE", "REPLACE", "MERGE", "UPSERT",
+	"CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME", "COMMENT",
+	"GRANT", "REVOKE",
+	"BEGIN", "START", "COMMIT", "END", "ROLLBACK", "SAVEPOINT", "RELEASE",
+	"SET", "USE", "LOCK", "VACUUM", "REINDEX", "ATTACH", "DETACH",
+}
+
+// returningRgx matches RETURNING clause,
+// which makes DML statements return rows.
+var returningRgx = regexp.MustCompile(`(?i)\bRETURNING\b`)
+
+// IsQuery reports whether the statement is expected to return rows,
+// so it must be executed with QueryData/QueryStream instead of Execute.
+//
+// Detection is based on the leading keyword
+// (or the one after the CTE list for WITH statements).
+// Unknown statements are treated as queries,
+// because querying a statement without rows is harmless,
+// while executing a statement with rows loses them.
+func IsQuery(query string) bool {
+	keyword := strings.ToUpper(leadingKeyword(query))
+	if keyword == "WITH" {
+		query = cteMain(query)
+		keyword = strings.ToUpper(leadingKeyword(query))
+	}
+	if !slice.Contains(execKeywords, keyword) {
+		return true
+	}
+	// DML statements with RETURNING clause are returning rows
+	return returningRgx.MatchString(query)
+}
+
+// callKeywords holds leading keywords of statements
+// that call stored procedures.
+var callKeywords = []string{"CALL", "EXEC", "EXECUTE"}
+
+// IsCall reports whether the statement calls a stored procedure,
+// so it may return multiple result sets.
+// Detection is based on the leading keyword, same as for IsQuery.
+func IsCall(query string) bool {
+	return slice.Contains(callKeywords, strings.ToUpper(leadingKeyword(query)))
+}
+
+// leadingKeyword returns the first word of the statement,
+// skipping leading whitespaces, comments and parentheses.
+func leadingKeyword(query string) string {
+	for {
+		query = strings.TrimLeftFunc(query, func(r rune) bool {
+			return unicode.IsSpace(r) || r == '('
+		})
+		switch {
+		case strings.HasPrefix(query, "--"), strings.HasPrefix(query, "#"):
+			// Skip line comment
+			end := strings.IndexByte(query, '\n')
+			if end == -1 {
+				return ""
+			}
+			query = query[end+1:]
+		case strings.HasPrefix(query, "/*"):
+			// Skip block comment
+			end := strings.Index(query, "*/")
+			if end == -1 {
+				return ""
+			}
+			query = query[end+2:]
+		default:
+			// Take the word
+			end := strings.IndexFunc(query, func(r rune) bool {
+				return !unicode.IsLetter(r)
+			})
+			if end == -1 {
+				return query
+			}
+			return query[:end]
+		}
+	}
+}
+
+// cteMain returns the main statement of the WITH query,
+// starting right after the CTE list.
+// CTE bodies are skipped, so their keywords (and RETURNING clauses) are not affecting detection.
+func cteMain(query string) string {
+	depth := 0
+	closed := false // Whether the last token closed a top-level parenthesis
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case strings.HasPrefix(query[i:], "--"), c == '#':
+			// Skip line comment
+			end := strings.IndexByte(query[i:], '\n')
+			if end == -1 {
+				return ""
+			}
+			i += end + 1
+		case strings.HasPrefix(query[i:], "/*"):
+			// Skip block comment
+			end := strings.Index(query[i:], "*/")
+			if end == -1 {
+				return ""
+			}
+			i += end + 2
+		case c == '\'', c == '"', c == '`':
+			// Skip literal or quoted identifier,
+			// doubled quotes are handled as two adjacent ones
+			end := strings.IndexByte(query[i+1:], c)
+			if end == -1 {
+				return ""
+			}
+			i, closed = i+end+2, false
+		case c == '(':
+			i, depth, closed = i+1, depth+1, false
+		case c == ')':
+			i, depth = i+1, depth-1
+			closed = depth == 0
+		case unicode.IsLetter(rune(c)):
+			// Word right after CTE body is the main statement keyword,
+			// the one after column list is AS
+			end := strings.IndexFunc(query[i:], func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+			})
+			if end == -1 {
+				end = len(query) - i
+			}
+			if depth == 0 && closed && !strings.EqualFold(query[i:i+end], "AS") {
+				return query[i:]
+			}
+			i, closed = i+end, false
+		case unicode.IsSpace(rune(c)):
+			i++
+		default:
+			i, closed = i+1, false
+		}
+	}
+	return ""
+}
+
+// delimiterRgx matches mysql client DELIMITER command,
+// which changes the statement delimiter (e.g. for procedure bodies).
+var delimiterRgx = regexp.MustCompile(`(?i)^DELIMITER[ \t]+(\S+)[^\n]*`)
+
+// dollarRgx matches postgres dollar-quote tag, like $$ or $body$.
+var dollarRgx = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// splitStatements splits the script into separate statements
+// according to the scheme's dialect.
+//
+// Delimiters inside of literals, quoted identifiers, comments
+// and postgres dollar-quoted strings are ignored.
+// MySQL DELIMITER command and SQLite trigger bodies (BEGIN ... END)
+// are supported as well.
+// Statements are returned without the delimiter,
+// statements without code (empty or comments only) are skipped.
+func splitStatements(scheme string, script string) []string {
+	var (
+		postgres = scheme == "postgres" || scheme == "postgresql"
+		mysql    = scheme == "mysql"
+		sqlite   = scheme == "sqlite" || scheme == "sqlite3"
+	)
+	var (
+		stmts []string
+		delim = ";"
+		start int      // Current statement start
+		code  bool     // Whether current statement has code (not only comments)
+		words []string // Leading words of the current statement (up to 4), to detect triggers
+		depth int      // Trigger body depth (BEGIN and CASE blocks), to detect trigger END
+	)
+	// flush appends the current statement, ending at i
+	flush := func(i int) {
+		if stmt := strings.TrimSpace(script[start:i]); code && stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		code, words, depth = false, nil, 0
+	}
+	// skip returns the index right after the first occurrence of the terminator,
+	// starting from i, or the script length if it's not found.
+	skip := func(i int, term string) int {
+		if end := strings.Index(script[i:], term); end != -1 {
+			return i + end + len(term)
+		}
+		return len(script)
+	}
+	// quoted returns the index right after the closing quote,
+	// handling doubled quotes and (optionally) backslash escapes.
+	quoted := func(i int, quote byte, backslash bool) int {
+		for j := i + 1; j < len(script); j++ {
+			switch {
+			case backslash && script[j] == '\\':
+				j++
+			case script[j] == quote && j+1 < len(script) && script[j+1] == quote:
+				j++
+			case script[j] == quote:
+				return j + 1
+			}
+		}
+		return len(script)
+	}
+	isword := func(b byte) bool {
+		return b == '_' || b == '$' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+	}
+	for i := 0; i < len(script); {
+		c := script[i]
+		switch {
+		case mysql && !code && (i == 0 || script[i-1] == '\n') && delimiterRgx.MatchString(script[i:]):
+			// Change the delimiter, command itself is not a statement
+			m := delimiterRgx.FindStringSubmatch(script[i:])
+			delim = m[1]
+			i += len(m[0])
+			start = i
+		case strings.HasPrefix(script[i:], "--"), mysql && c == '#':
+			// Line comment
+			i = skip(i, "\n")
+		case strings.HasPrefix(script[i:], "/*"):
+			// Block comment, postgres allows nesting
+			nesting := 0
+			for i < len(script) {
+				if strings.HasPrefix(script[i:], "/*") {
+					nesting++
+					i += 2
+				} else if strings.HasPrefix(script[i:], "*/") {
+					nesting--
+					i += 2
+					if nesting == 0 || !postgres {
+						break
+					}
+				} else {
+					i++
+				}
+			}
+		case strings.HasPrefix(script[i:], delim):
+			// Delimiter ends the statement,
+			// unless we're inside of sqlite trigger body
+			if depth > 0 {
+				i += len(delim)
+				continue
+			}
+			flush(i)
+			i += len(delim)
+			start = i
+		case c == '\'':
+			// String literal.
+			// MySQL and postgres E'' strings are allowing backslash escapes.
+			estring := postgres && i > 0 && (script[i-1] == 'E' || script[i-1] == 'e') && (i == 1 || !isword(script[i-2]))
+			i, code = quoted(i, c, mysql || estring), true
+		case c == '"', c == '`':
+			// Quoted identifier (or string literal for mysql)
+			i, code = quoted(i, c, mysql), true
+		case sqlite && c == '[':
+			// Quoted identifier, sqlite supports brackets for compatibility
+			i, code = skip(i, "]"), true
+		case postgres && c == '$' && (i == 0 || !isword(script[i-1])) && dollarRgx.MatchString(script[i:]):
+			// Dollar-quoted string
+			tag := dollarRgx.FindString(script[i:])
+			i, code = skip(i+len(tag), tag), true
+		case isword(c):
+			// Word, remember it for trigger detection
+			end := i
+			for end < len(script) && isword(script[end]) {
+				end++
+			}
+			word := strings.ToUpper(script[i:end])
+			if sqlite && len(words) < 4 {
+				words = append(words, word)
+			}
+			// Trigger body might contain CASE ... END expressions,
+			// so delimiter ends the trigger only when all blocks are closed
+			if len(words) > 1 && words[0] == "CREATE" && slice.Contains(words, "TRIGGER") {
+				switch word {
+				case "BEGIN", "CASE":
+					depth++
+				case "END":
+					depth--
+				}
+			}
+			i, code = end, true
+		case unicode.IsSpace(rune(c)):
+			i++
+		default:
+			i, code = i+1, true
+		}
+	}
+	flush(len(script))
+	return stmts
+}
@@ -0,0 +1,149 @@
+package ddb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"SELECT 1", true},
+		{"  -- comment\n/* block */ (SELECT 1)", true},
+		{"SHOW TABLES", true},
+		{"EXPLAIN SELECT 1", true},
+		{"INSERT INTO t VALUES (1)", false},
+		{"insert into t values (1) returning id", true},
+		{"UPDATE t SET a = 1 RETURNING *", true},
+		{"INSERT INTO returning_log VALUES (1)", false},
+		{"UPDATE t SET returning_at = now()", false},
+		{"CREATE TABLE t (id int)", false},
+		{"BEGIN", false},
+		{"WITH x AS (SELECT 1) SELECT * FROM x", true},
+		{"WITH x AS (SELECT 1) INSERT INTO t SELECT * FROM x", false},
+		{"WITH RECURSIVE x (n) AS (SELECT 1 UNION SELECT n + 1 FROM x) SELECT n FROM x", true},
+		{"WITH x AS (SELECT 1), y AS MATERIALIZED (SELECT 2) DELETE FROM t", false},
+		{"WITH x AS (SELECT ')') UPDATE t SET a = 1", false},
+		{"WITH x AS (SELECT 1) /* ( */ UPDATE t SET a = 1", false},
+		{"WITH x AS (SELECT 1) UPDATE t SET a = 1 RETURNING a", true},
+		{"WITH x AS (DELETE FROM t RETURNING *) INSERT INTO log SELECT * FROM x", false},
+		{"WITH x AS (DELETE FROM t RETURNING *) SELECT * FROM x", true},
+	}
+	for _, tt := range tests {
+		if got := IsQuery(tt.query); got != tt.want {
+			t.Errorf("IsQuery(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		scheme string
+		script string
+		want   []string
+	}{
+		{
+			"simple", "postgres",
+			"SELECT 1; SELECT 2;\n-- only comment;\n",
+			[]string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			"literals and identifiers", "postgres",
+			`SELECT ';', "a;b", 'it''s;'; SELECT 2`,
+			[]string{`SELECT ';', "a;b", 'it''s;'`, "SELECT 2"},
+		},
+		{
+			"dollar quotes", "postgres",
+			"CREATE FUNCTION f() RETURNS int AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql; SELECT $$;$$",
+			[]string{"CREATE FUNCTION f() RETURNS int AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql", "SELECT $$;$$"},
+		},
+		{
+			"dollar in identifier", "postgres",
+			"SELECT a$b$ FROM t; SELECT 2",
+			[]string{"SELECT a$b$ FROM t", "SELECT 2"},
+		},
+		{
+			"escape strings", "postgres",
+			`SELECT E'it\'s;'; SELECT 'C:\'; SELECT 3`,
+			[]string{`SELECT E'it\'s;'`, `SELECT 'C:\'`, "SELECT 3"},
+		},
+		{
+			"nested comments", "postgres",
+			"SELECT 1 /* outer /* inner; */ still comment; */; SELECT 2",
+			[]string{"SELECT 1 /* outer /* inner; */ still comment; */", "SELECT 2"},
+		},
+		{
+			"mysql comments are not nested", "mysql",
+			"SELECT 1 /* a /* b */; SELECT 2 # comment;\n",
+			[]string{"SELECT 1 /* a /* b */", "SELECT 2 # comment;"},
+		},
+		{
+			"mysql backslash escapes", "mysql",
+			`SELECT 'it\'s;', "a\";"; SELECT 2`,
+			[]string{`SELECT 'it\'s;', "a\";"`, "SELECT 2"},
+		},
+		{
+			"mysql delimiter", "mysql",
+			"DELIMITER //\nCREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END//\nDELIMITER ;\nCALL p();",
+			[]string{"CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END", "CALL p()"},
+		},
+		{
+			"delimiter is a command in mysql only", "postgres",
+			"DELIMITER //\nSELECT 1;",
+			[]string{"DELIMITER //\nSELECT 1"},
+		},
+		{
+			"sqlite trigger", "sqlite",
+			"CREATE TRIGGER t AFTER INSERT ON a BEGIN UPDATE a SET s = 1; DELETE FROM b; END; SELECT 1;",
+			[]string{"CREATE TRIGGER t AFTER INSERT ON a BEGIN UPDATE a SET s = 1; DELETE FROM b; END", "SELECT 1"},
+		},
+		{
+			"sqlite trigger with case", "sqlite",
+			"CREATE TRIGGER t AFTER INSERT ON a BEGIN SELECT CASE WHEN new.id THEN 1 END; UPDATE a SET s=1; END; SELECT 1",
+			[]string{"CREATE TRIGGER t AFTER INSERT ON a BEGIN SELECT CASE WHEN new.id THEN 1 END; UPDATE a SET s=1; END", "SELECT 1"},
+		},
+		{
+			"sqlite temp trigger", "sqlite3",
+			"CREATE TEMP TRIGGER IF NOT EXISTS t BEFORE DELETE ON a BEGIN SELECT RAISE(ABORT, 'no;'); END;",
+			[]string{"CREATE TEMP TRIGGER IF NOT EXISTS t BEFORE DELETE ON a BEGIN SELECT RAISE(ABORT, 'no;'); END"},
+		},
+		{
+			"sqlite transaction", "sqlite",
+			"BEGIN; INSERT INTO a VALUES (1); END;",
+			[]string{"BEGIN", "INSERT INTO a VALUES (1)", "END"},
+		},
+		{
+			"sqlite brackets", "sqlite",
+			"SELECT [a;b] FROM t; SELECT 2",
+			[]string{"SELECT [a;b] FROM t", "SELECT 2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.scheme, tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsCall(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"CALL report(1)", true},
+		{"  /* stats */ call report()", true},
+		{"EXEC dbo.report", true},
+		{"SELECT call FROM t", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsCall(tt.query); got != tt.want {
+			t.Errorf("IsCall(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package ddb
+
+// ReadStream reads the whole stream into a single Data struct pointer.
+// It's a bridge between streaming and buffered APIs,
+// so use it only when the result is expected to fit in memory.
+//
+// Stream is closed after reading, even if an error occurred.
+func ReadStream(stream Stream) (*Data, error) {
+	defer stream.Close()
+	// Initialize the Data struct with stream columns.
+	data := &Data{
+		Cols:  stream.Cols(),
+		Types: stream.Types(),
+	}
+	// Collect all chunks into the Data holder
+	for stream.Next() {
+		data.Rows = append(data.Rows, stream.Data().Rows...)
+	}
+	// Return with iteration error, if any
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ReadStreamSets reads all result sets of the stream into Data struct pointers,
+// one per set, in order of appearance.
+// Same memory considerations as for ReadStream apply.
+//
+// Stream is closed after reading, even if an error occurred.
+func ReadStreamSets(stream Stream) ([]*Data, error) {
+	defer stream.Close()
+	sets := []*Data{}
+	for {
+		// Initialize the Data struct with current set columns.
+		data := &Data{
+			Cols:  stream.Cols(),
+			Types: stream.Types(),
+		}
+		// Collect all chunks of the set
+		for stream.Next() {
+			data.Rows = append(data.Rows, stream.Data().Rows...)
+		}
+		sets = append(sets, data)
+		// Proceed to the next set, if any
+		if !stream.NextResultSet() {
+			break
+		}
+	}
+	// Return with iteration error, if any
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
@@ -0,0 +1,215 @@
+//go:build !daemon
+
+package ddb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// tunnel is an in-process SSH tunnel,
+// used to reach databases behind a bastion.
+// SSH connection is established on the first dial
+// and shared by all database connections.
+type tunnel struct {
+	host       string // SSH server address, with port
+	user       string
+	key        string // Private key file
+	knownhosts string // Known hosts file
+	agent      bool   // Authenticate with ssh-agent
+
+	mu     sync.Mutex
+	client *ssh.Client
+	agentc net.Conn // Agent connection, held until the tunnel is closed
+}
+
+// sshTunnel extracts ssh_* params from the DSN query (see dconf.Connection).
+// Params are removed from the query, because drivers don't know them.
+// Returns nil if tunnel is not requested (no ssh_host).
+func sshTunnel(query url.Values) (*tunnel, error) {
+	// Extract params
+	t := &tunnel{
+		host:       query.Get("ssh_host"),
+		user:       query.Get("ssh_user"),
+		key:        query.Get("ssh_key"),
+		knownhosts: query.Get("ssh_known_hosts"),
+	}
+	useagent := query.Get("ssh_agent")
+	for _, param := range []string{"ssh_host", "ssh_user", "ssh_key", "ssh_known_hosts", "ssh_agent"} {
+		query.Del(param)
+	}
+	if t.host == "" {
+		return nil, nil
+	}
+	// Apply defaults
+	if useagent != "" {
+		var err error
+		if t.agent, err = strconv.ParseBool(useagent); err != nil {
+			return nil, fmt.Errorf("invalid ssh_agent: %w", err)
+		}
+	}
+	if _, _, err := net.SplitHostPort(t.host); err != nil {
+		t.host = net.JoinHostPort(t.host, "22")
+	}
+	if t.user == "" {
+		t.user = os.Getenv("USER")
+	}
+	if t.knownhosts == "" {
+		t.knownhosts = filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts")
+	}
+	// Without explicit auth method,
+	// agent is used if available, default keys otherwise.
+	// Explicitly disabled agent is not used in any case.
+	if t.key == "" && useagent == "" && os.Getenv("SSH_AUTH_SOCK") != "" {
+		t.agent = true
+	}
+	return t, nil
+}
+
+// config composes SSH client config from the tunnel settings.
+func (t *tunnel) config() (*ssh.ClientConfig, error) {
+	// Server key must be known,
+	// we're not going to trust it blindly.
+	hostkey, err := knownhosts.New(t.knownhosts)
+	if err != nil {
+		return nil, fmt.Errorf("ssh known hosts: %w", err)
+	}
+	// Collect signers from the agent and key files
+	signers := []ssh.Signer{}
+	if t.agent {
+		// Agent connection is replaced on reconnect
+		if t.agentc != nil {
+			t.agentc.Close()
+		}
+		t.agentc, err = net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+		if err != nil {
+			return nil, fmt.Errorf("ssh agent: %w", err)
+		}
+		agentsigners, err := agent.NewClient(t.agentc).Signers()
+		if err != nil {
+			return nil, fmt.Errorf("ssh agent: %w", err)
+		}
+		signers = append(signers, agentsigners...)
+	}
+	keys := []string{t.key}
+	if t.key == "" && !t.agent {
+		home := os.Getenv("HOME")
+		keys = []string{
+			filepath.Join(home, ".ssh", "id_ed25519"),
+			filepath.Join(home, ".ssh", "id_ecdsa"),
+			filepath.Join(home, ".ssh", "id_rsa"),
+		}
+	}
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		pem, err := os.ReadFile(key)
+		if errors.Is(err, os.ErrNotExist) && t.key == "" {
+			continue // Default key is missing, it's fine
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ssh key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		if err != nil {
+			return nil, fmt.Errorf("ssh key %s: %w (use ssh-agent for keys with passphrase)", key, err)
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) == 0 {
+		return nil, errors.New("ssh: no keys to authenticate with")
+	}
+	return &ssh.ClientConfig{
+		User:            t.user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback: hostkey,
+	}, nil
+}
+
+// connect establishes SSH connection, if not yet established.
+func (t *tunnel) connect(ctx context.Context) (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client != nil {
+		return t.client, nil
+	}
+	config, err := t.config()
+	if err != nil {
+		return nil, err
+	}
+	// Dial with context, ssh package doesn't support it directly
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", t.host)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	sshconn, chans, reqs, err := ssh.NewClientConn(conn, t.host, config)
+	if err != nil {
+		conn.Close()
+		// Authentication failure is not a transient one
+		if strings.Contains(err.Error(), "unable to authenticate") {
+			return nil, connError(ErrAuth, err)
+		}
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	t.client = ssh.NewClient(sshconn, chans, reqs)
+	// Reset on disconnect, so the next dial reconnects
+	go func(client *ssh.Client) {
+		client.Wait()
+		t.mu.Lock()
+		if t.client == client {
+			t.client = nil
+		}
+		t.mu.Unlock()
+	}(t.client)
+	return t.client, nil
+}
+
+// DialContext opens a connection to the address through the SSH server.
+// Address is resolved on the server side.
+func (t *tunnel) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	client, err := t.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.DialContext(ctx, network, addr)
+	// Target is unreachable from the SSH server
+	var chanerr *ssh.OpenChannelError
+	if errors.As(err, &chanerr) {
+		return nil, connError(ErrNetwork, err)
+	}
+	return conn, err
+}
+
+// Close closes SSH connection and agent connection, if established.
+func (t *tunnel) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.agentc != nil {
+		t.agentc.Close()
+		t.agentc = nil
+	}
+	if t.client == nil {
+		return nil
+	}
+	err := t.client.Close()
+	t.client = nil
+	return err
+}
@@ -0,0 +1,316 @@
+//go:build !daemon
+
+package ddb
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testSigner generates an ed25519 key.
+// If path is provided, private key is written there as well.
+func testSigner(t *testing.T, path string) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "" {
+		block, err := ssh.MarshalPrivateKey(key, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signer
+}
+
+// testListen starts a TCP listener on the loopback interface,
+// closed on test cleanup.
+func testListen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln
+}
+
+// testEchoServer starts a TCP server, which writes back everything it reads.
+func testEchoServer(t *testing.T) string {
+	t.Helper()
+	ln := testListen(t)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// testSshServer starts an SSH server with the host key,
+// accepting the client key and serving direct-tcpip channels (port forwarding) only.
+func testSshServer(t *testing.T, hostkey ssh.Signer, clientkey ssh.PublicKey) string {
+	t.Helper()
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientkey.Marshal()) {
+				return nil, errors.New("unknown client key")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostkey)
+	ln := testListen(t)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go testSshServe(conn, config)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// testSshServe serves a single SSH client connection.
+func testSshServe(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	sshconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer sshconn.Close()
+	go ssh.DiscardRequests(reqs)
+	for newchan := range chans {
+		if newchan.ChannelType() != "direct-tcpip" {
+			newchan.Reject(ssh.UnknownChannelType, "port forwarding only")
+			continue
+		}
+		// See RFC 4254, section 7.2
+		var target struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err := ssh.Unmarshal(newchan.ExtraData(), &target); err != nil {
+			newchan.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		dst, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			newchan.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		ch, chreqs, err := newchan.Accept()
+		if err != nil {
+			dst.Close()
+			continue
+		}
+		go ssh.DiscardRequests(chreqs)
+		go func() {
+			defer ch.Close()
+			defer dst.Close()
+			go io.Copy(dst, ch)
+			io.Copy(ch, dst)
+		}()
+	}
+}
+
+func TestTunnel(t *testing.T) {
+	dir := t.TempDir()
+	hostkey := testSigner(t, "")
+	clientkey := testSigner(t, filepath.Join(dir, "id_ed25519"))
+	sshaddr := testSshServer(t, hostkey, clientkey.PublicKey())
+	echoaddr := testEchoServer(t)
+	// Known hosts files, with the server key and with another one
+	known := filepath.Join(dir, "known_hosts")
+	os.WriteFile(known, []byte(knownhosts.Line([]string{sshaddr}, hostkey.PublicKey())+"\n"), 0o600)
+	unknown := filepath.Join(dir, "unknown_hosts")
+	os.WriteFile(unknown, []byte(knownhosts.Line([]string{sshaddr}, testSigner(t, "").PublicKey())+"\n"), 0o600)
+	// Client key, not accepted by the server
+	testSigner(t, filepath.Join(dir, "id_other"))
+
+	tests := []struct {
+		name       string
+		knownhosts string
+		key        string
+		target     string
+		err        bool
+		kind       error // Expected connError kind, if any
+	}{
+		{"forwards to target", known, "id_ed25519", echoaddr, false, nil},
+		{"rejects unknown host key", unknown, "id_ed25519", echoaddr, true, nil},
+		{"rejects unknown client key", known, "id_other", echoaddr, true, ErrAuth},
+		{"unreachable target", known, "id_ed25519", "127.0.0.1:1", true, ErrNetwork},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := url.Values{
+				"ssh_host":        {sshaddr},
+				"ssh_user":        {"test"},
+				"ssh_key":         {filepath.Join(dir, tt.key)},
+				"ssh_known_hosts": {tt.knownhosts},
+			}
+			tun, err := sshTunnel(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tun.Close()
+			if len(query) != 0 {
+				t.Fatalf("ssh params are not removed: %s", query.Encode())
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, err := tun.DialContext(ctx, "tcp", tt.target)
+			if tt.err {
+				if err == nil {
+					conn.Close()
+					t.Fatal("expected an error")
+				}
+				var keyerr *knownhosts.KeyError
+				if tt.knownhosts == unknown && !errors.As(err, &keyerr) {
+					t.Fatalf("expected host key error, got %v", err)
+				}
+				if tt.kind != nil && !errors.Is(err, tt.kind) {
+					t.Fatalf("expected %v, got %v", tt.kind, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			// Check the data goes through the tunnel
+			msg := []byte("ping")
+			if _, err := conn.Write(msg); err != nil {
+				t.Fatal(err)
+			}
+			reply := make([]byte, len(msg))
+			if _, err := io.ReadFull(conn, reply); err != nil {
+				t.Fatal(err)
+			}
+			if string(reply) != string(msg) {
+				t.Fatalf("unexpected reply %q", reply)
+			}
+		})
+	}
+}
+
+func TestTunnelAgentParam(t *testing.T) {
+	tests := []struct {
+		name  string
+		param []string // ssh_agent value, absent if nil
+		key   string
+		sock  string // SSH_AUTH_SOCK
+		agent bool
+	}{
+		{"absent, agent available", nil, "", "/tmp/agent.sock", true},
+		{"absent, agent unavailable", nil, "", "", false},
+		{"absent, explicit key", nil, "id_ed25519", "/tmp/agent.sock", false},
+		{"explicitly disabled", []string{"false"}, "", "/tmp/agent.sock", false},
+		{"explicitly enabled", []string{"true"}, "id_ed25519", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SSH_AUTH_SOCK", tt.sock)
+			query := url.Values{"ssh_host": {"bastion"}, "ssh_key": {tt.key}}
+			if tt.param != nil {
+				query["ssh_agent"] = tt.param
+			}
+			tun, err := sshTunnel(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tun.agent != tt.agent {
+				t.Fatalf("agent = %v, want %v", tun.agent, tt.agent)
+			}
+		})
+	}
+}
+
+func TestTunnelAgent(t *testing.T) {
+	dir := t.TempDir()
+	hostkey := testSigner(t, "")
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientkey, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshaddr := testSshServer(t, hostkey, clientkey.PublicKey())
+	known := filepath.Join(dir, "known_hosts")
+	os.WriteFile(known, []byte(knownhosts.Line([]string{sshaddr}, hostkey.PublicKey())+"\n"), 0o600)
+	// Serve the agent with the client key,
+	// reporting when the client disconnects
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(dir, "agent.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	disconnected := make(chan struct{})
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		agent.ServeAgent(keyring, conn)
+		close(disconnected)
+	}()
+	t.Setenv("SSH_AUTH_SOCK", sock)
+
+	tun, err := sshTunnel(url.Values{"ssh_host": {sshaddr}, "ssh_user": {"test"}, "ssh_known_hosts": {known}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := tun.connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Agent connection must be released with the tunnel
+	tun.Close()
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent connection is not closed")
+	}
+}
@@ -1,6 +1,10 @@
 package ddb
 
-import "time"
+import (
+	"context"
+	"strings"
+	"time"
+)
 
 // Database interface summarizes the methods
 // that our utilities are going to use to interact with databases.
@@ -9,25 +13,151 @@ import "time"
 // by the database-specific struct.
 // On the other hand, database-agnostic methods might be implemented
 // on Connection struct, which nested into each database-specific struct.
+//
+// Each method has a context-aware variant (with Context suffix),
+// except transaction finalization.
+// Canceling the context cancels the in-flight statement,
+// server-side where the database allows it.
 type Database interface {
-	// Data queries
-	QueryData(query string) (*Data, error) // Return a pointer because data amount might be large
+	// Data queries.
+	// Arguments are bound to the query placeholders,
+	// which style depends on the database ($1 for postgres, ? for others).
+	QueryData(query string, args ...any) (*Data, error)    // Return a pointer because data amount might be large
+	QueryStream(query string, args ...any) (Stream, error) // Yields data in chunks, so we don't have to keep it in memory
+	QueryDataContext(ctx context.Context, query string, args ...any) (*Data, error)
+	QueryStreamContext(ctx context.Context, query string, args ...any) (Stream, error)
+
+	// Exec queries.
+	// Used for statements that don't return rows (DML/DDL),
+	// see IsQuery to determine the right path.
+	Execute(query string, args ...any) (*Result, error)
+	ExecuteContext(ctx context.Context, query string, args ...any) (*Result, error)
+
+	// Transactions.
+	// While transaction is active, all statements are running within it.
+	// Commit and Rollback have no context variants, they can't be canceled.
+	Begin() error
+	BeginContext(ctx context.Context) error
+	Commit() error
+	Rollback() error
+
+	// Syntax helpers
+	QuoteIdent(ident string) string         // Quotes an identifier (table, column, etc.) in the database-specific way
+	SplitStatements(script string) []string // Splits the script into separate statements in the database-specific way
 
 	// Schema queries
 	QueryTables() ([]Table, error)
-	QueryColumns(table string) ([]Column, error)
+	QueryColumns(table TableIdent) ([]Column, error)
+	QueryTablesContext(ctx context.Context) ([]Table, error)
+	QueryColumnsContext(ctx context.Context, table TableIdent) ([]Column, error)
+	QueryIndexes(table TableIdent) ([]Index, error)
+	QueryIndexesContext(ctx context.Context, table TableIdent) ([]Index, error)
+	QueryConstraints(table TableIdent) ([]Constraint, error)
+	QueryConstraintsContext(ctx context.Context, table TableIdent) ([]Constraint, error)
+	QueryTableStats() ([]TableStats, error)
+	QueryTableStatsContext(ctx context.Context) ([]TableStats, error)
+	QueryDatabases() ([]DatabaseInfo, error)
+	QueryDatabasesContext(ctx context.Context) ([]DatabaseInfo, error)
 
 	// Process queries
 	QueryProcesses() ([]Process, error)
 	KillProcess(pid int, force bool) error
+	QueryProcessesContext(ctx context.Context) ([]Process, error)
+	KillProcessContext(ctx context.Context, pid int, force bool) error
+
+	// Lock queries
+	QueryLocks() ([]Lock, error)
+	QueryLocksContext(ctx context.Context) ([]Lock, error)
+
+	// Access queries
+	QueryRoles() ([]Role, error)
+	QueryRolesContext(ctx context.Context) ([]Role, error)
+	QueryGrants() ([]Grant, error)
+	QueryGrantsContext(ctx context.Context) ([]Grant, error)
 }
 
 // Data holds query results.
 // Columns and rows are stored separately instead of using maps,
 // so we can minimize memory usage and output.
 type Data struct {
-	Cols []string
-	Rows [][]any
+	Cols  []string
+	Types []ColumnType // Columns metadata, might be empty (e.g. for data composed by tools)
+	Rows  [][]any
+}
+
+// ColumnType holds result column metadata, reported by the driver.
+// Not every driver reports everything,
+// so each group of values has a flag indicating its presence.
+type ColumnType struct {
+	DatabaseType string // Database type name, like VARCHAR or INT4. Empty if unknown
+
+	Nullable    bool
+	HasNullable bool
+
+	Length    int64 // Length of variable-length types, like VARCHAR
+	HasLength bool
+
+	Precision    int64 // Decimal types only
+	Scale        int64
+	HasPrecision bool
+}
+
+// IsNumeric reports whether the column holds numbers,
+// judging by the database type name.
+func (t ColumnType) IsNumeric() bool {
+	name := strings.ToUpper(t.DatabaseType)
+	for _, numeric := range []string{"INT", "DEC", "NUMERIC", "FLOAT", "DOUBLE", "REAL", "SERIAL", "MONEY"} {
+		if strings.Contains(name, numeric) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBinary reports whether the column holds raw bytes,
+// judging by the database type name.
+func (t ColumnType) IsBinary() bool {
+	name := strings.ToUpper(t.DatabaseType)
+	for _, binary := range []string{"BLOB", "BYTEA", "BINARY"} {
+		if strings.Contains(name, binary) {
+			return true
+		}
+	}
+	return false
+}
+
+// Result holds statement execution summary,
+// for statements that don't return rows.
+type Result struct {
+	RowsAffected int64
+	LastInsertId int64 // Zero, if not supported by the database (e.g. postgres)
+}
+
+// StreamChunk is the maximum number of rows
+// that Stream yields in a single chunk.
+const StreamChunk = 1000
+
+// Stream is an incremental reader of query results.
+// Unlike Data, it doesn't hold the whole result in memory,
+// but yields rows in chunks as they arrive from the database.
+//
+// Usage is similar to sql.Rows:
+//
+//	for stream.Next() {
+//		data := stream.Data() // Current chunk
+//	}
+//	err := stream.Err()
+//
+// Statements returning multiple result sets (e.g. stored procedures)
+// are iterated set by set with NextResultSet, after the rows of the current set.
+type Stream interface {
+	Cols() []string      // Result columns, available before the first Next call
+	Types() []ColumnType // Result columns metadata, same as Cols
+	Next() bool          // Advances to the next chunk, returns false when there are no more rows
+	NextResultSet() bool // Advances to the next result set (if statement returns many), Cols and Types are updated
+	Data() *Data         // Current chunk
+	Err() error          // Error occurred during iteration, if any
+	Close() error
 }
 
 // Table holds table meta information,
@@ -35,27 +165,197 @@ type Data struct {
 type Table struct {
 	Schema   string
 	Name     string
+	Kind     TableKind
 	IsSystem bool // Indicates whether it's a system table
+
+	// Definition holds the view query (SELECT statement),
+	// only for views and materialized views.
+	Definition string
+}
+
+// TableKind determines the kind of the table-like relation.
+type TableKind string
+
+const (
+	TableKindTable            TableKind = "table"
+	TableKindView             TableKind = "view"
+	TableKindMaterializedView TableKind = "materialized view"
+	TableKindForeignTable     TableKind = "foreign table"
+	TableKindSequence         TableKind = "sequence"
+)
+
+// IsView reports whether the relation is a view (regular or materialized),
+// so it's defined by a query instead of columns.
+func (k TableKind) IsView() bool {
+	return k == TableKindView || k == TableKindMaterializedView
+}
+
+// Ident returns the table identifier.
+func (t Table) Ident() TableIdent {
+	return TableIdent{Schema: t.Schema, Name: t.Name}
 }
 
 // Column holds column meta information.
+// Keys and other constraints are provided separately,
+// see Constraint.
 type Column struct {
 	Name       string
 	Type       string
-	IsPrimary  bool
+	IsPrimary  bool // Indicates whether column is a part of the primary key
 	IsNullable bool
 	Default    any
+}
+
+// Constraint holds table constraint meta information.
+// Column-level constraints are represented the same way as table-level ones,
+// so a single column constraint just has a single column.
+type Constraint struct {
+	Name    string // Might be empty, if database doesn't name constraints (sqlite)
+	Type    ConstraintType
+	Columns []string // Empty for check constraints, if database doesn't report them
+
+	// Foreign key information.
+	// Foreign columns are matching Columns by position,
+	// empty if the key references the primary key implicitly (sqlite).
+	ForeignTable   TableIdent
+	ForeignColumns []string
+	OnUpdate       string
+	OnDelete       string
+
+	// Check constraint expression, without CHECK keyword.
+	Check string
+}
+
+// ConstraintType determines the kind of the constraint.
+type ConstraintType string
+
+const (
+	ConstraintPrimaryKey ConstraintType = "PRIMARY KEY"
+	ConstraintForeignKey ConstraintType = "FOREIGN KEY"
+	ConstraintUnique     ConstraintType = "UNIQUE"
+	ConstraintCheck      ConstraintType = "CHECK"
+)
+
+// primaryColumns returns the names of the primary key columns.
+// Used by drivers to mark columns, resolved with QueryColumns.
+func primaryColumns(constraints []Constraint) []string {
+	for _, c := range constraints {
+		if c.Type == ConstraintPrimaryKey {
+			return c.Columns
+		}
+	}
+	return nil
+}
+
+// Index holds index meta information.
+type Index struct {
+	Name      string
+	Columns   []string // Key column names, expressions are wrapped in parentheses
+	Options   []string // Key column options (operator class, DESC, NULLS FIRST), same order as Columns, empty for defaults
+	Include   []string // Non-key columns (INCLUDE clause), postgres only
+	IsUnique  bool
+	IsPrimary bool
+	Predicate string // Partial index condition (WHERE clause), empty if index is not partial
+	Method    string // Index access method, like btree or hash
+}
+
+// TableStats holds table size and statistics information.
+// Values are estimated by the database, so they might be inaccurate
+// (e.g. row count is updated on analyze).
+// Zero time means the database doesn't track it, or it never happened.
+type TableStats struct {
+	Schema      string
+	Name        string
+	Rows        int64 // Estimated row count, zero if unknown (e.g. not analyzed yet)
+	TotalSize   int64 // Total size in bytes, including indexes
+	IndexSize   int64 // Indexes size in bytes
+	LastAnalyze time.Time
+	LastVacuum  time.Time
+}
+
+// Ident returns the table identifier.
+func (t TableStats) Ident() TableIdent {
+	return TableIdent{Schema: t.Schema, Name: t.Name}
+}
 
-	// Foreign key information
-	ForeignRef      string
-	ForeignOnUpdate string
-	ForeignOnDelete string
+// DatabaseInfo holds information about the database on the server.
+// Details, not provided by the database, are left empty.
+type DatabaseInfo struct {
+	Name      string
+	Owner     string
+	Encoding  string // Character set, like UTF8 or utf8mb4
+	Size      int64  // Size in bytes, zero if not accessible
+	IsCurrent bool   // Database of the current connection
 }
 
+// Process holds database process (connection) information.
+// Details, not provided by the database, are left empty.
 type Process struct {
+	Pid         int
+	Duration    time.Duration // Current (or last) query duration
+	Username    string
+	Database    string
+	Query       string
+	State       string    // Connection state, like active, idle or idle in transaction
+	Client      string    // Client address, empty for local (unix socket) connections
+	Application string    // Application name, reported by the client
+	WaitEvent   string    // What the process is waiting for (e.g. Lock:transactionid), empty if not waiting
+	XactStart   time.Time // Current transaction start, zero if not in transaction
+	BlockedBy   []int     // PIDs of the processes holding locks, this one is waiting for
+}
+
+// Lock holds information about the lock,
+// held (granted) or awaited by the process.
+type Lock struct {
 	Pid      int
-	Duration time.Duration
+	Schema   string // Locked table schema, empty if lock is not on a table (e.g. transaction id)
+	Table    string
+	Type     string // Lock type, like relation, tuple or transactionid (postgres), TABLE or RECORD (mysql)
+	Mode     string // Lock mode, like AccessShareLock (postgres) or X,REC_NOT_GAP (mysql)
+	Granted  bool   // False means the process is waiting for the lock
 	Username string
-	Database string
+	Duration time.Duration // Current (or last) query duration of the process
 	Query    string
 }
+
+// Ident returns the locked table identifier.
+func (l Lock) Ident() TableIdent {
+	return TableIdent{Schema: l.Schema, Name: l.Table}
+}
+
+// Role holds database role (user) information.
+// For mysql, role name is an account name (user@host).
+type Role struct {
+	Name      string
+	CanLogin  bool     // False for group roles (postgres) and locked accounts (mysql)
+	Superuser bool     // Postgres superuser, or mysql SUPER privilege holder
+	Inherit   bool     // Whether privileges of the granted roles are inherited (false for postgres NOINHERIT roles)
+	MemberOf  []string // Roles, granted to this one
+}
+
+// Grant holds a privilege, granted to the role.
+// Schema and table are set according to the privilege level,
+// e.g. both are empty for global and database privileges.
+type Grant struct {
+	Grantee   string // Role name, or PUBLIC (postgres)
+	Level     GrantLevel
+	Schema    string
+	Table     string
+	Privilege string // Privilege type, like SELECT or INSERT
+	Grantable bool   // Grantee is allowed to grant the privilege to others
+
+	// Cascades indicates whether the privilege applies to the tables within the level as well.
+	// True for mysql global and schema (database) privileges,
+	// false for postgres ones, which are about the database or schema itself (e.g. CONNECT or USAGE).
+	Cascades bool
+}
+
+// GrantLevel determines the object, the privilege is granted on.
+type GrantLevel string
+
+const (
+	GrantLevelGlobal   GrantLevel = "global"   // Whole server (mysql)
+	GrantLevelDatabase GrantLevel = "database" // Current database (postgres)
+	GrantLevelSchema   GrantLevel = "schema"   // Schema (database for mysql)
+	GrantLevelTable    GrantLevel = "table"
+)
@@ -0,0 +1,29 @@
+package ddb
+
+import "testing"
+
+func TestColumnTypeKind(t *testing.T) {
+	tests := []struct {
+		dbtype  string
+		numeric bool
+		binary  bool
+	}{
+		{"INTEGER", true, false},
+		{"int8", true, false},
+		{"NUMERIC", true, false},
+		{"DOUBLE PRECISION", true, false},
+		{"BIGSERIAL", true, false},
+		{"TEXT", false, false},
+		{"VARCHAR", false, false},
+		{"BLOB", false, true},
+		{"bytea", false, true},
+		{"VARBINARY", false, true},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		ct := ColumnType{DatabaseType: tt.dbtype}
+		if ct.IsNumeric() != tt.numeric || ct.IsBinary() != tt.binary {
+			t.Errorf("%q: numeric %v, binary %v, want %v, %v", tt.dbtype, ct.IsNumeric(), ct.IsBinary(), tt.numeric, tt.binary)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+// Package dext is a single place to compile extra database drivers into dsh tools.
+// All tools import it, so a driver registered here is available everywhere.
+//
+// A driver is a package that calls ddb.Register from its init function.
+// To include it, add a file with a blank import to this package,
+// optionally guarded by a build tag, e.g. dext_oracle.go:
+//
+//	//go:build oracle
+//
+//	package dext
+//
+//	import _ "example.com/dsh-oracle"
+//
+// Then build with the tag: go build -tags oracle ./cmd/...
+// Compiled-in drivers are listed with "dsh drivers".
+package dext
@@ -0,0 +1,27 @@
+package dio
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// Context returns a context for the tool's database calls.
+// It's canceled on interrupt signal (Ctrl-C)
+// or when the timeout is exceeded (zero means no timeout).
+// Canceling the context also cancels the in-flight statement.
+func Context(timeout time.Duration) (context.Context, context.CancelFunc) {
+	// Cancel on interrupt signal
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	// Return as is, if no timeout provided
+	if timeout <= 0 {
+		return ctx, stop
+	}
+	// Otherwise, apply timeout as well
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
@@ -2,20 +2,21 @@ package dio
 
 import (
 	"encoding/csv"
-	"fmt"
 	"io"
 
 	"github.com/yznts/dsh/pkg/ddb"
-	"go.kyoto.codes/zen/v3/slice"
 )
 
 // Csv is a writer that writes data as a csv.
+// Each result (data, execution summary or stream result set)
+// is written as a separate table with its own header,
+// tables are separated by a blank line.
 type Csv struct {
 	*csv.Writer
 
-	// flushed determines if the writer has been flushed.
-	// If it hasn't, the first table write will write the columns.
-	flushed bool
+	// started determines if any table has been written.
+	// If it has, the next one is separated by a blank line.
+	started bool
 }
 
 // write wraps the csv writer's Write method.
@@ -29,6 +30,40 @@ func (c *Csv) write(record []string) {
 	}
 }
 
+// flush flushes the csv writer.
+// If an error occurs, it panics.
+func (c *Csv) flush() {
+	c.Flush()
+	if c.Error() != nil {
+		panic(c.Error())
+	}
+}
+
+// header starts a new table with the columns,
+// separated from the previous one (if any) by a blank line.
+func (c *Csv) header(cols []string) {
+	if c.started {
+		c.write(nil)
+	}
+	c.started = true
+	c.write(cols)
+}
+
+// rows writes data rows to the current table.
+func (c *Csv) rows(data *ddb.Data) {
+	for _, row := range data.Rows {
+		// Convert the row to a string slice,
+		// keeping values close to the database representation.
+		rowstr := make([]string, len(row))
+		for i, v := range row {
+			rowstr[i] = text(v, coltype(data, i))
+		}
+		// Write the row.
+		c.write(rowstr)
+	}
+	c.flush()
+}
+
 // Multi returns true if the writer supports multiple writes.
 // Csv supports multiple writes.
 func (c *Csv) Multi() bool {
@@ -37,29 +72,40 @@ func (c *Csv) Multi() bool {
 
 func (c *Csv) WriteError(err error) {
 	c.write([]string{err.Error()})
+	c.flush()
 }
 
+// WriteData writes data as a separate table.
 func (c *Csv) WriteData(data *ddb.Data) {
-	// If it's the first write (no flushes), write the columns.
-	if !c.flushed {
-		c.flushed = true
-		c.write(data.Cols)
-	}
-	// Write the rows.
-	for _, row := range data.Rows {
-		// Convert the row to a string slice.
-		rowstr := slice.Map(row, func(v any) string {
-			return fmt.Sprintf("%v", v)
-		})
-		// Write the row.
-		c.write(rowstr)
-	}
-	// Flush the writer.
-	c.Flush()
-	// If an error occurs, panic.
-	if c.Error() != nil {
-		panic(c.Error())
+	c.header(data.Cols)
+	c.rows(data)
+}
+
+// WriteResult writes execution summary as a separate table.
+func (c *Csv) WriteResult(result *ddb.Result) {
+	c.WriteData(&ddb.Data{
+		Cols: []string{"rows_affected", "last_insert_id"},
+		Rows: [][]any{{result.RowsAffected, result.LastInsertId}},
+	})
+}
+
+// WriteStream writes the stream chunk by chunk.
+// Each result set is a separate table,
+// columns are available before the rows,
+// so the header is written even for an empty set.
+func (c *Csv) WriteStream(stream ddb.Stream) error {
+	defer stream.Close()
+	for {
+		c.header(stream.Cols())
+		for stream.Next() {
+			c.rows(stream.Data())
+		}
+		c.flush()
+		if !stream.NextResultSet() {
+			break
+		}
 	}
+	return stream.Err()
 }
 
 func NewCsv(w io.Writer) *Csv {
@@ -0,0 +1,58 @@
+package dio
+
+import (
+	"testing"
+
+	"github.com/yznts/dsh/pkg/ddb"
+)
+
+func TestCsvWriteStream(t *testing.T) {
+	tests := []struct {
+		name string
+		sets [][]*ddb.Data
+		want string
+	}{
+		{
+			"empty set has a header",
+			[][]*ddb.Data{testChunks([]string{"a"}, 0, 2)},
+			"a\n",
+		},
+		{
+			"chunks are written as a single table",
+			[][]*ddb.Data{testChunks([]string{"a", "b"}, 3, 2)},
+			"a,b\n0,0\n1,1\n2,2\n",
+		},
+		{
+			"each set has its own header",
+			[][]*ddb.Data{testChunks([]string{"a"}, 1, 2), testChunks([]string{"b", "c"}, 0, 2), testChunks([]string{"d"}, 2, 2)},
+			"a\n0\n\nb,c\n\nd\n0\n1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &testStream{sets: tt.sets}
+			out := newTestOutput(stream)
+			if err := NewCsv(out).WriteStream(stream); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Fatalf("got %q, want %q", out.String(), tt.want)
+			}
+			if !stream.closed {
+				t.Fatal("stream is not closed")
+			}
+		})
+	}
+}
+
+func TestCsvMultipleResults(t *testing.T) {
+	out := newTestOutput(nil)
+	c := NewCsv(out)
+	c.WriteData(&ddb.Data{Cols: []string{"id", "name"}, Rows: [][]any{{int64(1), "a,b"}}})
+	c.WriteResult(&ddb.Result{RowsAffected: 2, LastInsertId: 3})
+	c.WriteResult(&ddb.Result{RowsAffected: 1})
+	want := "id,name\n1,\"a,b\"\n\nrows_affected,last_insert_id\n2,3\n\nrows_affected,last_insert_id\n1,0\n"
+	if out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+}
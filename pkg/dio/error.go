@@ -3,6 +3,8 @@ package dio
 import (
 	"errors"
 	"os"
+
+	"github.com/yznts/dsh/pkg/ddb"
 )
 
 // Assert checks if the error presents,
@@ -21,3 +23,18 @@ func Assert(w Writer, err error, override ...string) {
 		os.Exit(1)
 	}
 }
+
+// errkind resolves a short kind and a hint for the known error types,
+// like connection failures, so writers can render them clearly.
+// Empty strings are returned for other errors.
+func errkind(err error) (kind string, hint string) {
+	switch {
+	case errors.Is(err, ddb.ErrAuth):
+		return "auth", "check the username and password"
+	case errors.Is(err, ddb.ErrDatabaseMissing):
+		return "database_missing", "check the database name (or file path for sqlite)"
+	case errors.Is(err, ddb.ErrNetwork):
+		return "network", "check the host and port (or socket), and that the server is running"
+	}
+	return "", ""
+}
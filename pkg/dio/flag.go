@@ -0,0 +1,37 @@
+package dio
+
+import (
+	"flag"
+	"strings"
+)
+
+// Strings is a flag value that collects repeated flag occurrences,
+// like `-arg 1 -arg 2`, into a slice.
+type Strings []string
+
+func (s *Strings) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *Strings) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// Any converts collected values to a slice of any,
+// so it can be passed as query arguments.
+func (s *Strings) Any() []any {
+	vals := make([]any, len(*s))
+	for i, v := range *s {
+		vals[i] = v
+	}
+	return vals
+}
+
+// StringsFlag defines a repeatable string flag,
+// same way as flag.String does for a single value.
+func StringsFlag(name string, usage string) *Strings {
+	s := &Strings{}
+	flag.Var(s, name, usage)
+	return s
+}
@@ -16,6 +16,10 @@ import (
 // that's why it's called Gloss.
 type Gloss struct {
 	w io.WriteCloser
+
+	// sets determines if we're writing multiple results (see BeginSets),
+	// so the writer is not closed after each table.
+	sets bool
 }
 
 // write wraps the io writer's Write method.
@@ -42,21 +46,49 @@ func (g *Gloss) WriteError(err error) {
 		Bold(true).
 		Render(fmt.Sprintf("error occured: %s", err.Error()))
 	g.write([]byte(msg + "\n"))
+	// Known errors are followed by a hint
+	if _, hint := errkind(err); hint != "" {
+		msg = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#f66f81")).
+			Render(fmt.Sprintf("hint: %s", hint))
+		g.write([]byte(msg + "\n"))
+	}
 	// No need to close writer, because it's just an error message.
 	// We can write more data after that.
 }
 
 func (g *Gloss) WriteData(data *ddb.Data) {
+	// Write table
+	g.table(data)
+	// Close writer.
+	// After the table is written, it cannot be appended to.
+	// If someone will try to write once more, it will panic.
+	// In sets mode, each result is a separate table,
+	// so closing is deferred until EndSets.
+	if g.sets {
+		return
+	}
+	if err := g.w.Close(); err != nil {
+		panic(err)
+	}
+}
+
+// table renders the data as a table and writes it.
+func (g *Gloss) table(data *ddb.Data) {
 	// Transform rows to string
-	rowsstr := slice.Map(data.Rows, func(v []any) []string {
-		return slice.Map(v, func(v any) string {
-			// If value is []uint8, don't print it, just mark as not supported.
+	rowsstr := slice.Map(data.Rows, func(row []any) []string {
+		rowstr := make([]string, len(row))
+		for i, v := range row {
+			v = value(v, coltype(data, i))
+			// If value is still []uint8, don't print it, just mark as not supported.
 			// Probably this type is a blob or something that driver can't convert.
 			if _, ok := v.([]uint8); ok {
-				return "<n/s>"
+				rowstr[i] = "<n/s>"
+				continue
 			}
-			return fmt.Sprintf("%v", v)
-		})
+			rowstr[i] = fmt.Sprintf("%v", v)
+		}
+		return rowstr
 	})
 	// Create table
 	t := table.New().
@@ -65,6 +97,9 @@ func (g *Gloss) WriteData(data *ddb.Data) {
 		StyleFunc(func(row, col int) lipgloss.Style {
 			if row == 0 {
 				return lipgloss.NewStyle().Foreground(lipgloss.Color("99")).Bold(true).Padding(0, 2)
+			} else if coltype(data, col).IsNumeric() {
+				// Numbers are easier to compare when right-aligned
+				return lipgloss.NewStyle().MaxHeight(5).MaxWidth(80).Padding(0, 2).Align(lipgloss.Right)
 			} else {
 				return lipgloss.NewStyle().MaxHeight(5).MaxWidth(80).Padding(0, 2)
 			}
@@ -73,12 +108,56 @@ func (g *Gloss) WriteData(data *ddb.Data) {
 		Rows(rowsstr...)
 	// Write table
 	g.write([]byte(t.String() + "\n"))
-	// Close writer.
-	// After the table is written, it cannot be appended to.
-	// If someone will try to write once more, it will panic.
-	if err := g.w.Close(); err != nil {
-		panic(err)
+}
+
+// WriteStream writes the stream as a table,
+// or a separate table per result set if statement returns many.
+// Table layout depends on the whole data (i.e. column widths),
+// so we can't write rows as they arrive.
+// Instead, rows are rendered in chunks of ddb.StreamChunk rows,
+// each chunk is a separate table,
+// so we're never holding more than a chunk in memory.
+func (g *Gloss) WriteStream(stream ddb.Stream) error {
+	defer stream.Close()
+	for {
+		data := &ddb.Data{Cols: stream.Cols(), Types: stream.Types()}
+		written := false // Whether the set was written at least partially
+		for stream.Next() {
+			data.Rows = append(data.Rows, stream.Data().Rows...)
+			if len(data.Rows) >= ddb.StreamChunk {
+				g.table(data)
+				data.Rows, written = nil, true
+			}
+		}
+		// Write the rest of the set.
+		// Empty set is written as an empty table, unless it's failed.
+		if len(data.Rows) > 0 || !written && stream.Err() == nil {
+			g.table(data)
+		}
+		if !stream.NextResultSet() {
+			break
+		}
 	}
+	// Close writer, same as WriteData does
+	if !g.sets {
+		if err := g.w.Close(); err != nil {
+			panic(err)
+		}
+	}
+	return stream.Err()
+}
+
+// WriteResult writes a summary line of the executed statement.
+func (g *Gloss) WriteResult(result *ddb.Result) {
+	summary := fmt.Sprintf("OK, %d rows affected", result.RowsAffected)
+	if result.LastInsertId != 0 {
+		summary += fmt.Sprintf(", last insert id %d", result.LastInsertId)
+	}
+	msg := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("99")).
+		Bold(true).
+		Render(summary)
+	g.write([]byte(msg + "\n"))
 }
 
 func (g *Gloss) WriteWarning(msg string) {
@@ -91,6 +170,20 @@ func (g *Gloss) WriteWarning(msg string) {
 	// We can write more data after that.
 }
 
+// BeginSets switches the writer to sets mode,
+// so multiple results are written as separate tables.
+func (g *Gloss) BeginSets() {
+	g.sets = true
+}
+
+// EndSets closes the writer.
+func (g *Gloss) EndSets() {
+	g.sets = false
+	if err := g.w.Close(); err != nil {
+		panic(err)
+	}
+}
+
 func NewGloss(w io.WriteCloser) *Gloss {
 	return &Gloss{w: w}
 }
@@ -0,0 +1,63 @@
+package dio
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/yznts/dsh/pkg/ddb"
+)
+
+func TestGlossWriteStream(t *testing.T) {
+	cols := []string{"ID"}
+	tests := []struct {
+		name   string
+		sets   [][]*ddb.Data
+		err    error
+		tables int // Expected number of rendered tables
+		first  int // Expected chunks pulled before the first write
+	}{
+		{"empty", [][]*ddb.Data{testChunks(cols, 0, 500)}, nil, 1, 0},
+		{"single chunk", [][]*ddb.Data{testChunks(cols, 10, 500)}, nil, 1, 1},
+		{"rendered by chunks", [][]*ddb.Data{testChunks(cols, 2500, 500)}, nil, 3, 2},
+		{"multiple sets", [][]*ddb.Data{testChunks(cols, 10, 500), testChunks(cols, 0, 500), testChunks(cols, 1200, 500)}, nil, 4, 1},
+		{"failed", [][]*ddb.Data{testChunks(cols, 0, 500)}, errors.New("failed"), 0, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &testStream{sets: tt.sets, err: tt.err}
+			out := newTestOutput(stream)
+			err := NewGloss(out).WriteStream(stream)
+			if err != tt.err {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if tables := strings.Count(out.String(), "┌"); tables != tt.tables {
+				t.Fatalf("expected %d tables, got %d:\n%s", tt.tables, tables, out.String())
+			}
+			if out.first != tt.first {
+				t.Fatalf("expected first write after %d chunks, got %d", tt.first, out.first)
+			}
+			if !stream.closed || !out.closed {
+				t.Fatal("stream and writer must be closed")
+			}
+		})
+	}
+}
+
+func TestGlossWriteStreamSets(t *testing.T) {
+	// In sets mode, writer stays open for the next results
+	stream := &testStream{sets: [][]*ddb.Data{testChunks([]string{"ID"}, 10, 500)}}
+	out := newTestOutput(stream)
+	gloss := NewGloss(out)
+	gloss.BeginSets()
+	if err := gloss.WriteStream(stream); err != nil {
+		t.Fatal(err)
+	}
+	if out.closed {
+		t.Fatal("writer is closed before EndSets")
+	}
+	gloss.EndSets()
+	if !out.closed {
+		t.Fatal("writer is not closed after EndSets")
+	}
+}
@@ -1,15 +1,27 @@
 package dio
 
 import (
+	"errors"
 	"io"
 
 	"github.com/yznts/dsh/pkg/ddb"
 	"go.kyoto.codes/zen/v3/jsonx"
+	"go.kyoto.codes/zen/v3/slice"
 )
 
 // Json is a writer that writes a single json object.
 type Json struct {
 	w io.WriteCloser
+
+	// sets determines if we're writing multiple results,
+	// as a JSON array (see BeginSets).
+	// count holds the number of results written so far.
+	sets  bool
+	count int
+
+	// multiset determines if streams are written
+	// as a JSON array of result sets (see ExpectSets).
+	multiset bool
 }
 
 // write wraps the io writer's Write method.
@@ -17,11 +29,32 @@ type Json struct {
 // It's unexpected behavior in our case,
 // so panic is necessary.
 func (j *Json) write(data []byte) {
-	data = append(data, '\n')
 	if _, err := j.w.Write(data); err != nil {
 		panic(err)
 	}
+}
+
+// next prepares the output for the next object.
+// In sets mode, objects are separated by comma.
+func (j *Json) next() {
+	if !j.sets {
+		return
+	}
+	if j.count > 0 {
+		j.write([]byte{','})
+	}
+	j.count++
+}
 
+// close writes a trailing newline and closes the writer.
+// Json writer outputs a single object,
+// so nothing can be written after that.
+// In sets mode, it's deferred until EndSets.
+func (j *Json) close() {
+	if j.sets {
+		return
+	}
+	j.write([]byte{'\n'})
 	if err := j.w.Close(); err != nil {
 		panic(err)
 	}
@@ -36,14 +69,143 @@ func (j *Json) Multi() bool {
 
 func (j *Json) WriteError(err error) {
 	errmap := map[string]any{"ERROR": err.Error()}
+	if kind, hint := errkind(err); kind != "" {
+		errmap["KIND"], errmap["HINT"] = kind, hint
+	}
+	j.next()
 	j.write(jsonx.Bytes(errmap))
+	j.close()
 }
 
 func (j *Json) WriteData(data *ddb.Data) {
-	j.write(jsonx.Bytes(map[string]any{
+	obj := map[string]any{
 		"COLS": data.Cols,
-		"ROWS": data.Rows,
+		"ROWS": slice.Map(data.Rows, func(row []any) []any {
+			return j.row(data, row)
+		}),
+	}
+	// Include database types, if available
+	if len(data.Types) > 0 {
+		obj["TYPES"] = j.types(data.Types)
+	}
+	j.next()
+	j.write(jsonx.Bytes(obj))
+	j.close()
+}
+
+// row normalizes row values,
+// so we're not losing value types on marshaling (e.g. text as base64 bytes).
+func (j *Json) row(data *ddb.Data, row []any) []any {
+	vals := make([]any, len(row))
+	for i, v := range row {
+		vals[i] = value(v, coltype(data, i))
+	}
+	return vals
+}
+
+// types extracts database type names from columns metadata.
+func (j *Json) types(types []ddb.ColumnType) []string {
+	return slice.Map(types, func(t ddb.ColumnType) string {
+		return t.DatabaseType
+	})
+}
+
+func (j *Json) WriteResult(result *ddb.Result) {
+	j.next()
+	j.write(jsonx.Bytes(map[string]any{
+		"ROWS_AFFECTED":  result.RowsAffected,
+		"LAST_INSERT_ID": result.LastInsertId,
 	}))
+	j.close()
+}
+
+// WriteStream writes the stream as a single json object,
+// same as WriteData does.
+// The difference is that rows are written chunk by chunk,
+// so we're composing the object manually instead of marshaling it at once.
+//
+// If the statement is expected to return multiple result sets (see ExpectSets),
+// they are written as a JSON array of such objects.
+// Otherwise, the shape is already chosen by the time the second set arrives,
+// so it's reported as an error instead of breaking the output.
+func (j *Json) WriteStream(stream ddb.Stream) error {
+	defer stream.Close()
+	j.next()
+	if j.multiset {
+		j.write([]byte{'['})
+	}
+	unexpected := false
+	for set := 0; ; set++ {
+		if set > 0 {
+			j.write([]byte{','})
+		}
+		j.set(stream)
+		if !stream.NextResultSet() {
+			break
+		}
+		if !j.multiset {
+			unexpected = true
+			break
+		}
+	}
+	if j.multiset {
+		j.write([]byte{']'})
+	}
+	j.close()
+	// Return stream error, if any
+	if err := stream.Err(); err != nil {
+		return err
+	}
+	if unexpected {
+		return errors.New("statement returned multiple result sets, only the first one is written")
+	}
+	return nil
+}
+
+// set writes the current result set of the stream as a json object.
+func (j *Json) set(stream ddb.Stream) {
+	// Open the object and rows array
+	j.write([]byte(`{"COLS":`))
+	j.write(jsonx.Bytes(stream.Cols()))
+	if types := stream.Types(); len(types) > 0 {
+		j.write([]byte(`,"TYPES":`))
+		j.write(jsonx.Bytes(j.types(types)))
+	}
+	j.write([]byte(`,"ROWS":[`))
+	// Write rows, separated by comma
+	first := true
+	for stream.Next() {
+		data := stream.Data()
+		for _, row := range data.Rows {
+			if !first {
+				j.write([]byte{','})
+			}
+			first = false
+			j.write(jsonx.Bytes(j.row(data, row)))
+		}
+	}
+	// Close rows array and the object
+	j.write([]byte(`]}`))
+}
+
+// ExpectSets determines whether the following streams
+// are written as a JSON array of result sets, even if there is a single one.
+func (j *Json) ExpectSets(multiset bool) {
+	j.multiset = multiset
+}
+
+// BeginSets switches the writer to sets mode,
+// so multiple results are written as a JSON array of objects.
+func (j *Json) BeginSets() {
+	j.write([]byte{'['})
+	j.sets = true
+}
+
+// EndSets closes the JSON array and the writer.
+func (j *Json) EndSets() {
+	j.sets = false
+	j.write([]byte{']'})
+	j.close()
 }
 
 func NewJson(w io.WriteCloser) *Json {
@@ -0,0 +1,89 @@
+package dio
+
+import (
+	"testing"
+
+	"github.com/yznts/dsh/pkg/ddb"
+)
+
+func TestJsonWriteStream(t *testing.T) {
+	tests := []struct {
+		name     string
+		sets     [][]*ddb.Data
+		multiset bool
+		want     string
+		err      bool
+	}{
+		{
+			"single set",
+			[][]*ddb.Data{testChunks([]string{"a"}, 3, 2)},
+			false,
+			`{"COLS":["a"],"ROWS":[[0],[1],[2]]}` + "\n",
+			false,
+		},
+		{
+			"empty set",
+			[][]*ddb.Data{testChunks([]string{"a"}, 0, 2)},
+			false,
+			`{"COLS":["a"],"ROWS":[]}` + "\n",
+			false,
+		},
+		{
+			"expected sets",
+			[][]*ddb.Data{testChunks([]string{"a"}, 1, 2), testChunks([]string{"b"}, 0, 2)},
+			true,
+			`[{"COLS":["a"],"ROWS":[[0]]},{"COLS":["b"],"ROWS":[]}]` + "\n",
+			false,
+		},
+		{
+			"expected sets, single one",
+			[][]*ddb.Data{testChunks([]string{"a"}, 1, 2)},
+			true,
+			`[{"COLS":["a"],"ROWS":[[0]]}]` + "\n",
+			false,
+		},
+		{
+			"unexpected sets",
+			[][]*ddb.Data{testChunks([]string{"a"}, 1, 2), testChunks([]string{"b"}, 1, 2)},
+			false,
+			`{"COLS":["a"],"ROWS":[[0]]}` + "\n",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &testStream{sets: tt.sets}
+			out := newTestOutput(stream)
+			json := NewJson(out)
+			json.ExpectSets(tt.multiset)
+			if err := json.WriteStream(stream); (err != nil) != tt.err {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if out.String() != tt.want {
+				t.Fatalf("got %q, want %q", out.String(), tt.want)
+			}
+			if !stream.closed || !out.closed {
+				t.Fatal("stream and writer must be closed")
+			}
+		})
+	}
+}
+
+func TestJsonWriteStreamRows(t *testing.T) {
+	// Rows are written as they arrive,
+	// without waiting for the stream to be drained
+	stream := &testStream{sets: [][]*ddb.Data{testChunks([]string{"a"}, 10, 2)}}
+	out := newTestOutput(stream)
+	written := ""
+	stream.onNext = func() {
+		if stream.pulled == 5 {
+			written = out.String()
+		}
+	}
+	if err := NewJson(out).WriteStream(stream); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"COLS":["a"],"ROWS":[[0],[1],[2],[3],[4],[5],[6],[7]`; written != want {
+		t.Fatalf("on the last chunk, got %q written, want %q", written, want)
+	}
+}
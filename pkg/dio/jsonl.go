@@ -34,19 +34,56 @@ func (j *Jsonl) Multi() bool {
 
 func (j *Jsonl) WriteError(err error) {
 	errmap := map[string]any{"error": err.Error()}
+	if kind, hint := errkind(err); kind != "" {
+		errmap["kind"], errmap["hint"] = kind, hint
+	}
 	j.write(jsonx.Bytes(errmap))
 }
 
 func (j *Jsonl) WriteData(data *ddb.Data) {
+	j.rows(data, 0)
+}
+
+// rows writes data rows, line per row.
+// Rows of additional result sets (set > 0) are tagged with the set index,
+// so output of a regular single set query stays the same.
+func (j *Jsonl) rows(data *ddb.Data, set int) {
 	for _, row := range data.Rows {
 		obj := map[string]any{}
 		for i, col := range data.Cols {
-			obj[col] = row[i]
+			obj[col] = value(row[i], coltype(data, i))
+		}
+		if set > 0 {
+			obj["_set"] = set
 		}
 		j.write(jsonx.Bytes(obj))
 	}
 }
 
+func (j *Jsonl) WriteResult(result *ddb.Result) {
+	j.write(jsonx.Bytes(map[string]any{
+		"rows_affected":  result.RowsAffected,
+		"last_insert_id": result.LastInsertId,
+	}))
+}
+
+// WriteStream writes the stream chunk by chunk,
+// line per row.
+// Multiple result sets are written one after another,
+// lines are tagged with "_set" index starting from the second set.
+func (j *Jsonl) WriteStream(stream ddb.Stream) error {
+	defer stream.Close()
+	for set := 0; ; set++ {
+		for stream.Next() {
+			j.rows(stream.Data(), set)
+		}
+		if !stream.NextResultSet() {
+			break
+		}
+	}
+	return stream.Err()
+}
+
 func NewJsonl(w io.Writer) *Jsonl {
 	return &Jsonl{w: w}
 }
@@ -3,10 +3,10 @@ package dio
 import (
 	"fmt"
 	"io"
+	"math"
 	"strings"
 
 	"github.com/yznts/dsh/pkg/ddb"
-	"go.kyoto.codes/zen/v3/jsonx"
 	"go.kyoto.codes/zen/v3/slice"
 )
 
@@ -19,8 +19,23 @@ import (
 type Sql struct {
 	w io.Writer
 
-	mode  string // one of "data", "schema"
+	mode  string // one of "data", "schema", "index", "view"
 	table string
+
+	// quote is used to quote column names,
+	// usually it's a Database.QuoteIdent.
+	// If not set, names are written as-is.
+	quote func(string) string
+
+	// constraints are written as a part of
+	// CREATE TABLE statement ("schema" mode only).
+	constraints []ddb.Constraint
+
+	// ddl determines if typed CREATE TABLE statement
+	// must be written before the data ("data" mode only).
+	// It's written once, on the first write.
+	ddl     bool
+	ddldone bool
 }
 
 // write wraps the io writer's Write method.
@@ -53,10 +68,17 @@ func (s *Sql) WriteData(data *ddb.Data) {
 	// If we're writing schema, we need to write a CREATE TABLE statement
 	// with taking data rows as column definitions.
 	if s.mode == "schema" {
-		// Convert data rows to column definitions
-		col := strings.Join(slice.Map(data.Rows, func(row []any) string {
-			return fmt.Sprintf("%s %s", row[0], row[1])
-		}), ", \n")
+		// Convert data rows to column definitions,
+		// followed by table constraints.
+		defs := slice.Map(data.Rows, func(row []any) string {
+			def := fmt.Sprintf("%s %s", s.ident(fmt.Sprint(row[0])), row[1])
+			if nullable, ok := row[3].(bool); ok && !nullable {
+				def += " NOT NULL"
+			}
+			return def
+		})
+		defs = append(defs, slice.Map(s.constraints, s.constraint)...)
+		col := strings.Join(defs, ", \n")
 		// Write the CREATE TABLE statement
 		stm := fmt.Sprintf("CREATE TABLE %s (\n%s);\n\n", s.table, col)
 		// Write the statement and return
@@ -64,11 +86,38 @@ func (s *Sql) WriteData(data *ddb.Data) {
 		return
 	}
 
+	// If we're writing a view, we need to write a CREATE VIEW statement
+	// with taking data row as a view kind and definition (query).
+	if s.mode == "view" {
+		for _, row := range data.Rows {
+			stm := fmt.Sprintf("CREATE %s %s AS\n%s;\n\n",
+				strings.ToUpper(fmt.Sprint(row[0])), s.table, strings.TrimSuffix(strings.TrimSpace(fmt.Sprint(row[1])), ";"))
+			s.write([]byte(stm))
+		}
+		return
+	}
+
+	// If we're writing indexes, we need to write a CREATE INDEX statement
+	// for each data row (index definition).
+	if s.mode == "index" {
+		for _, row := range data.Rows {
+			s.writeIndex(row)
+		}
+		return
+	}
+
 	// Otherwise, we're writing INSERT statement
 	// with taking data rows as values.
 
+	// If requested, precede the data with a table definition,
+	// composed from the columns metadata.
+	if s.ddl && !s.ddldone {
+		s.ddldone = true
+		s.writeDDL(data)
+	}
+
 	// First, let's write the INSERT statement.
-	col := strings.Join(data.Cols, ", ")
+	col := strings.Join(slice.Map(data.Cols, s.ident), ", ")
 	stm := fmt.Sprintf("INSERT INTO %s (%s) VALUES\n", s.table, col)
 	s.write([]byte(stm))
 
@@ -78,14 +127,12 @@ func (s *Sql) WriteData(data *ddb.Data) {
 		if i != 0 {
 			s.write([]byte(",\n"))
 		}
-		// Convert the row to a string slice.
-		rowstr := strings.Join(slice.Map(row, func(val any) string {
-			valstr := jsonx.String(val)
-			if valstr[0] == '"' {
-				valstr = fmt.Sprintf("'%s'", valstr[1:len(valstr)-1])
-			}
-			return valstr
-		}), ", ")
+		// Convert the row to a sql literals.
+		vals := make([]string, len(row))
+		for i, val := range row {
+			vals[i] = literal(val, coltype(data, i))
+		}
+		rowstr := strings.Join(vals, ", ")
 		s.write([]byte(fmt.Sprintf("(%s)", rowstr)))
 	}
 
@@ -93,17 +140,189 @@ func (s *Sql) WriteData(data *ddb.Data) {
 	s.write([]byte(";\n\n"))
 }
 
+// constraint composes a table constraint definition.
+// Default foreign key actions (NO ACTION) are omitted.
+func (s *Sql) constraint(c ddb.Constraint) string {
+	def := ""
+	if c.Name != "" {
+		def = fmt.Sprintf("CONSTRAINT %s ", s.ident(c.Name))
+	}
+	cols := strings.Join(slice.Map(c.Columns, s.ident), ", ")
+	switch c.Type {
+	case ddb.ConstraintForeignKey:
+		def += fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s", cols, c.ForeignTable.Quoted(s.ident))
+		if len(c.ForeignColumns) > 0 {
+			def += fmt.Sprintf(" (%s)", strings.Join(slice.Map(c.ForeignColumns, s.ident), ", "))
+		}
+		if c.OnUpdate != "" && c.OnUpdate != "NO ACTION" {
+			def += " ON UPDATE " + c.OnUpdate
+		}
+		if c.OnDelete != "" && c.OnDelete != "NO ACTION" {
+			def += " ON DELETE " + c.OnDelete
+		}
+	case ddb.ConstraintCheck:
+		def += fmt.Sprintf("CHECK (%s)", c.Check)
+	default:
+		def += fmt.Sprintf("%s (%s)", c.Type, cols)
+	}
+	return def
+}
+
+// writeIndex writes a CREATE INDEX statement from the index definition row.
+// Expected row layout is: name, columns ([]string), is unique, is primary, method, predicate,
+// and optionally include ([]string) and column options ([]string, matching columns by position).
+// Primary key indexes are skipped, they're a part of the table definition.
+//
+// Expression columns (wrapped in parentheses) are written as-is, followed by their options (e.g. DESC).
+// Default b-tree method is omitted, FULLTEXT and SPATIAL (mysql) are written as index modifiers,
+// other methods are written as USING clause (postgres).
+func (s *Sql) writeIndex(row []any) {
+	// Skip primary key
+	if isprimary, _ := row[3].(bool); isprimary {
+		return
+	}
+	// Compose index modifier and method
+	kind := "INDEX"
+	if isunique, _ := row[2].(bool); isunique {
+		kind = "UNIQUE INDEX"
+	}
+	method := ""
+	switch m := strings.ToUpper(fmt.Sprint(row[4])); m {
+	case "", "BTREE":
+	case "FULLTEXT", "SPATIAL":
+		kind = m + " INDEX"
+	default:
+		method = fmt.Sprintf(" USING %s", row[4])
+	}
+	// Compose columns
+	cols, _ := row[1].([]string)
+	opts := []string{}
+	if len(row) > 7 {
+		opts, _ = row[7].([]string)
+	}
+	keys := make([]string, len(cols))
+	for i, c := range cols {
+		keys[i] = c
+		if !strings.HasPrefix(c, "(") {
+			keys[i] = s.ident(c)
+		}
+		if i < len(opts) && opts[i] != "" {
+			keys[i] += " " + opts[i]
+		}
+	}
+	// Compose non-key columns
+	include := ""
+	if len(row) > 6 {
+		if inc, _ := row[6].([]string); len(inc) > 0 {
+			include = fmt.Sprintf(" INCLUDE (%s)", strings.Join(slice.Map(inc, s.ident), ", "))
+		}
+	}
+	// Compose predicate
+	where := ""
+	if pred := fmt.Sprint(row[5]); pred != "" {
+		where = fmt.Sprintf(" WHERE %s", pred)
+	}
+	// Write the statement
+	stm := fmt.Sprintf("CREATE %s %s ON %s%s (%s)%s%s;\n\n",
+		kind, s.ident(fmt.Sprint(row[0])), s.table, method, strings.Join(keys, ", "), include, where)
+	s.write([]byte(stm))
+}
+
+// writeDDL writes a CREATE TABLE statement,
+// composed from the data columns metadata.
+// Columns without known database type are left untyped.
+func (s *Sql) writeDDL(data *ddb.Data) {
+	cols := make([]string, len(data.Cols))
+	for i, col := range data.Cols {
+		cols[i] = strings.TrimSpace(fmt.Sprintf("%s %s", s.ident(col), sqltype(coltype(data, i))))
+	}
+	stm := fmt.Sprintf("CREATE TABLE %s (\n%s);\n\n", s.table, strings.Join(cols, ", \n"))
+	s.write([]byte(stm))
+}
+
+// sqltype composes a column type definition from the metadata,
+// including length, precision/scale and nullability where reported.
+func sqltype(t ddb.ColumnType) string {
+	if t.DatabaseType == "" {
+		return ""
+	}
+	def := t.DatabaseType
+	name := strings.ToUpper(t.DatabaseType)
+	// Drivers are reporting max int as length/precision for unlimited types,
+	// so we're ignoring such values.
+	switch {
+	case t.HasPrecision && t.Precision > 0 && t.Precision < math.MaxInt32 &&
+		(strings.Contains(name, "DEC") || strings.Contains(name, "NUMERIC")):
+		def += fmt.Sprintf("(%d,%d)", t.Precision, t.Scale)
+	case t.HasLength && t.Length > 0 && t.Length < math.MaxInt32 &&
+		(strings.Contains(name, "CHAR") || strings.Contains(name, "BINARY")):
+		def += fmt.Sprintf("(%d)", t.Length)
+	}
+	if t.HasNullable && !t.Nullable {
+		def += " NOT NULL"
+	}
+	return def
+}
+
+// WriteResult writes execution summary as a sql comment,
+// so the output remains a valid sql.
+func (s *Sql) WriteResult(result *ddb.Result) {
+	s.write([]byte(fmt.Sprintf("-- %d rows affected, last insert id %d\n\n", result.RowsAffected, result.LastInsertId)))
+}
+
+// WriteStream writes the stream chunk by chunk,
+// so each chunk results in a separate statement.
+func (s *Sql) WriteStream(stream ddb.Stream) error {
+	defer stream.Close()
+	for {
+		for stream.Next() {
+			s.WriteData(stream.Data())
+		}
+		if !stream.NextResultSet() {
+			break
+		}
+	}
+	return stream.Err()
+}
+
+// ident quotes the column name, if quote function is set.
+func (s *Sql) ident(name string) string {
+	if s.quote == nil {
+		return name
+	}
+	return s.quote(name)
+}
+
 // SetMode sets the mode of the writer.
-// It can be either "data" or "schema".
+// It can be either "data", "schema", "index" or "view".
 func (s *Sql) SetMode(mode string) {
 	s.mode = mode
 }
 
+// SetDDL enables typed CREATE TABLE statement before the data
+// (only for "data" mode).
+func (s *Sql) SetDDL(ddl bool) {
+	s.ddl = ddl
+}
+
 // SetTable sets the table name.
+// It's written as-is, so it must be quoted by the caller if needed.
 func (s *Sql) SetTable(table string) {
 	s.table = table
 }
 
+// SetConstraints sets the table constraints,
+// written as a part of CREATE TABLE statement (only for "schema" mode).
+func (s *Sql) SetConstraints(constraints []ddb.Constraint) {
+	s.constraints = constraints
+}
+
+// SetQuote sets the function to quote column names,
+// usually it's a Database.QuoteIdent.
+func (s *Sql) SetQuote(quote func(string) string) {
+	s.quote = quote
+}
+
 // NewSql creates a new Sql writer.
 func NewSql(w io.Writer) *Sql {
 	return &Sql{w: w}
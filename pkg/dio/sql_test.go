@@ -0,0 +1,92 @@
+package dio
+
+import (
+	"testing"
+
+	"github.com/yznts/dsh/pkg/ddb"
+)
+
+func TestSqlWriteIndex(t *testing.T) {
+	cols := []string{"INDEX_NAME", "COLUMNS", "IS_UNIQUE", "IS_PK", "METHOD", "PREDICATE", "INCLUDE", "OPTIONS"}
+	quote := func(s string) string { return `"` + s + `"` }
+	tests := []struct {
+		name string
+		row  []any
+		want string
+	}{
+		{
+			"plain",
+			[]any{"i", []string{"a", "b"}, false, false, "btree", ""},
+			"CREATE INDEX \"i\" ON t (\"a\", \"b\");\n\n",
+		},
+		{
+			"primary is skipped",
+			[]any{"pk", []string{"id"}, true, true, "btree", ""},
+			"",
+		},
+		{
+			"unique partial with method",
+			[]any{"i", []string{"a"}, true, false, "hash", "a > 0"},
+			"CREATE UNIQUE INDEX \"i\" ON t USING hash (\"a\") WHERE a > 0;\n\n",
+		},
+		{
+			"options, expressions and include",
+			[]any{"i", []string{"a", "(lower(b))"}, false, false, "btree", "", []string{"c"}, []string{"DESC NULLS LAST", ""}},
+			"CREATE INDEX \"i\" ON t (\"a\" DESC NULLS LAST, (lower(b))) INCLUDE (\"c\");\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := newTestOutput(nil)
+			s := NewSql(out)
+			s.SetMode("index")
+			s.SetTable("t")
+			s.SetQuote(quote)
+			s.WriteData(&ddb.Data{Cols: cols, Rows: [][]any{tt.row}})
+			if out.String() != tt.want {
+				t.Fatalf("got %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSqlWriteSchema(t *testing.T) {
+	out := newTestOutput(nil)
+	s := NewSql(out)
+	s.SetMode("schema")
+	s.SetTable(`"child"`)
+	s.SetQuote(func(s string) string { return `"` + s + `"` })
+	s.SetConstraints([]ddb.Constraint{
+		{Type: ddb.ConstraintPrimaryKey, Columns: []string{"a", "b"}},
+		{
+			Name:           "fk_parent",
+			Type:           ddb.ConstraintForeignKey,
+			Columns:        []string{"pid"},
+			ForeignTable:   ddb.TableIdent{Schema: "app", Name: "parent"},
+			ForeignColumns: []string{"id"},
+			OnUpdate:       "NO ACTION",
+			OnDelete:       "CASCADE",
+		},
+		{Type: ddb.ConstraintForeignKey, Columns: []string{"oid"}, ForeignTable: ddb.TableIdent{Name: "other"}},
+		{Type: ddb.ConstraintUnique, Columns: []string{"code"}},
+		{Name: "positive", Type: ddb.ConstraintCheck, Check: "a > 0"},
+	})
+	s.WriteData(&ddb.Data{
+		Cols: []string{"COLUMN_NAME", "DATA_TYPE", "IS_PK", "IS_NULLABLE", "DEFAULT"},
+		Rows: [][]any{
+			{"a", "INTEGER", true, false, nil},
+			{"code", "TEXT", false, true, nil},
+		},
+	})
+	want := "CREATE TABLE \"child\" (\n" +
+		"\"a\" INTEGER NOT NULL, \n" +
+		"\"code\" TEXT, \n" +
+		"PRIMARY KEY (\"a\", \"b\"), \n" +
+		"CONSTRAINT \"fk_parent\" FOREIGN KEY (\"pid\") REFERENCES \"app\".\"parent\" (\"id\") ON DELETE CASCADE, \n" +
+		"FOREIGN KEY (\"oid\") REFERENCES \"other\", \n" +
+		"UNIQUE (\"code\"), \n" +
+		"CONSTRAINT \"positive\" CHECK (a > 0));\n\n"
+	if out.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,111 @@
+package dio
+
+import (
+	"bytes"
+
+	"github.com/yznts/dsh/pkg/ddb"
+)
+
+// testStream is an in-memory ddb.Stream,
+// yielding prepared chunks set by set.
+type testStream struct {
+	sets   [][]*ddb.Data // Chunks of each result set, first chunk holds the columns
+	err    error         // Error, reported after the last chunk
+	set    int
+	chunk  int  // Index of the next chunk within the set
+	pulled int  // Total number of pulled chunks
+	done   bool // Whether the last set is drained
+	closed bool
+
+	onNext func() // Called on each pulled chunk, if set
+}
+
+// testChunks splits rows into chunks of the given size.
+// Set without rows still has a single empty chunk to hold the columns.
+func testChunks(cols []string, rows int, size int) []*ddb.Data {
+	chunks := []*ddb.Data{{Cols: cols}}
+	for i := 0; i < rows; i++ {
+		last := chunks[len(chunks)-1]
+		if len(last.Rows) == size {
+			last = &ddb.Data{Cols: cols}
+			chunks = append(chunks, last)
+		}
+		row := make([]any, len(cols))
+		for j := range cols {
+			row[j] = int64(i)
+		}
+		last.Rows = append(last.Rows, row)
+	}
+	return chunks
+}
+
+func (s *testStream) Cols() []string {
+	return s.sets[s.set][0].Cols
+}
+
+func (s *testStream) Types() []ddb.ColumnType {
+	return nil
+}
+
+func (s *testStream) Next() bool {
+	chunks := s.sets[s.set]
+	if s.chunk >= len(chunks) || len(chunks[s.chunk].Rows) == 0 {
+		s.done = s.set == len(s.sets)-1
+		return false
+	}
+	s.chunk++
+	s.pulled++
+	if s.onNext != nil {
+		s.onNext()
+	}
+	return true
+}
+
+func (s *testStream) NextResultSet() bool {
+	if s.set+1 >= len(s.sets) {
+		return false
+	}
+	s.set, s.chunk = s.set+1, 0
+	return true
+}
+
+func (s *testStream) Data() *ddb.Data {
+	return s.sets[s.set][s.chunk-1]
+}
+
+func (s *testStream) Err() error {
+	if !s.done {
+		return nil
+	}
+	return s.err
+}
+
+func (s *testStream) Close() error {
+	s.closed = true
+	return nil
+}
+
+// testOutput is an in-memory writer,
+// remembering how many chunks were pulled from the stream on the first write.
+type testOutput struct {
+	bytes.Buffer
+	stream *testStream
+	first  int // Chunks pulled on the first write, -1 if nothing was written
+	closed bool
+}
+
+func newTestOutput(stream *testStream) *testOutput {
+	return &testOutput{stream: stream, first: -1}
+}
+
+func (o *testOutput) Write(p []byte) (int, error) {
+	if o.first == -1 && o.stream != nil {
+		o.first = o.stream.pulled
+	}
+	return o.Buffer.Write(p)
+}
+
+func (o *testOutput) Close() error {
+	o.closed = true
+	return nil
+}
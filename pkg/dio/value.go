@@ -0,0 +1,81 @@
+package dio
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yznts/dsh/pkg/ddb"
+)
+
+// coltype returns metadata of the i-th data column.
+// Data might have no metadata at all (e.g. composed by tools),
+// in that case zero value is returned.
+func coltype(data *ddb.Data, i int) ddb.ColumnType {
+	if i < len(data.Types) {
+		return data.Types[i]
+	}
+	return ddb.ColumnType{}
+}
+
+// value normalizes the database value for output.
+// Drivers are often returning text as raw bytes,
+// so we're converting them to strings unless column is binary.
+func value(v any, t ddb.ColumnType) any {
+	if b, ok := v.([]byte); ok && !t.IsBinary() && utf8.Valid(b) {
+		return string(b)
+	}
+	return v
+}
+
+// text formats the database value as a plain text,
+// keeping it as close to the database representation as possible.
+// Used by text-only formats, like csv.
+func text(v any, t ddb.ColumnType) string {
+	switch v := value(v, t).(type) {
+	case nil:
+		return ""
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// literal formats the database value as a sql literal.
+func literal(v any, t ddb.ColumnType) string {
+	switch v := value(v, t).(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'"
+	case time.Time:
+		return quote(v.Format("2006-01-02 15:04:05.999999999Z07:00"))
+	default:
+		return quote(fmt.Sprintf("%v", v))
+	}
+}
+
+// quote wraps the string into single quotes,
+// escaping quotes inside.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,76 @@
+package dio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yznts/dsh/pkg/ddb"
+)
+
+func TestText(t *testing.T) {
+	blob := ddb.ColumnType{DatabaseType: "BLOB"}
+	tests := []struct {
+		name string
+		v    any
+		t    ddb.ColumnType
+		want string
+	}{
+		{"null", nil, ddb.ColumnType{}, ""},
+		{"text bytes", []byte("hello"), ddb.ColumnType{DatabaseType: "TEXT"}, "hello"},
+		{"untyped bytes", []byte("hello"), ddb.ColumnType{}, "hello"},
+		{"binary column", []byte("hello"), blob, "aGVsbG8="},
+		{"invalid utf8", []byte{0xff, 0xfe}, ddb.ColumnType{}, "//4="},
+		{"float", 0.1, ddb.ColumnType{}, "0.1"},
+		{"large float", 1e21, ddb.ColumnType{}, "1000000000000000000000"},
+		{"time", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), ddb.ColumnType{}, "2024-01-02T03:04:05Z"},
+	}
+	for _, tt := range tests {
+		if got := text(tt.v, tt.t); got != tt.want {
+			t.Errorf("%s: text() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		t    ddb.ColumnType
+		want string
+	}{
+		{"null", nil, ddb.ColumnType{}, "NULL"},
+		{"bool", true, ddb.ColumnType{}, "TRUE"},
+		{"int", int64(42), ddb.ColumnType{}, "42"},
+		{"float", 1.5, ddb.ColumnType{}, "1.5"},
+		{"string", "it's", ddb.ColumnType{}, "'it''s'"},
+		{"text bytes", []byte("abc"), ddb.ColumnType{DatabaseType: "VARCHAR"}, "'abc'"},
+		{"binary column", []byte("abc"), ddb.ColumnType{DatabaseType: "BYTEA"}, "X'616263'"},
+		{"time", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), ddb.ColumnType{}, "'2024-01-02 03:04:05Z'"},
+	}
+	for _, tt := range tests {
+		if got := literal(tt.v, tt.t); got != tt.want {
+			t.Errorf("%s: literal() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSqltype(t *testing.T) {
+	tests := []struct {
+		name string
+		t    ddb.ColumnType
+		want string
+	}{
+		{"unknown", ddb.ColumnType{}, ""},
+		{"plain", ddb.ColumnType{DatabaseType: "INTEGER"}, "INTEGER"},
+		{"length", ddb.ColumnType{DatabaseType: "VARCHAR", Length: 64, HasLength: true}, "VARCHAR(64)"},
+		{"unlimited length", ddb.ColumnType{DatabaseType: "VARCHAR", Length: 1<<63 - 1, HasLength: true}, "VARCHAR"},
+		{"precision", ddb.ColumnType{DatabaseType: "NUMERIC", Precision: 10, Scale: 2, HasPrecision: true}, "NUMERIC(10,2)"},
+		{"not null", ddb.ColumnType{DatabaseType: "TEXT", HasNullable: true}, "TEXT NOT NULL"},
+		{"nullable", ddb.ColumnType{DatabaseType: "TEXT", Nullable: true, HasNullable: true}, "TEXT"},
+	}
+	for _, tt := range tests {
+		if got := sqltype(tt.t); got != tt.want {
+			t.Errorf("%s: sqltype() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -7,6 +7,8 @@ import "github.com/yznts/dsh/pkg/ddb"
 type Writer interface {
 	Multi() bool // Multi returns true if the writer supports multiple writes.
 	WriteData(*ddb.Data)
+	WriteStream(ddb.Stream) error // Consumes the stream chunk by chunk, returns the stream error (if any)
+	WriteResult(*ddb.Result)      // Writes execution summary of the statement without rows
 	WriteError(error)
 }
 
@@ -15,3 +17,20 @@ type Writer interface {
 type WarningWriter interface {
 	WriteWarning(string)
 }
+
+// SetsWriter is an optional interface that can be implemented by writers.
+// It allows writers to hold multiple results (e.g. of multiple statements)
+// in a single output, like a JSON array.
+// All writes between BeginSets and EndSets are treated as separate results.
+type SetsWriter interface {
+	BeginSets()
+	EndSets()
+}
+
+// MultiSetWriter is an optional interface that can be implemented by writers.
+// It tells the writer upfront whether the next streams may hold multiple result sets
+// (see ddb.IsCall), for writers that must choose the output shape
+// before the first set is written, like a JSON array of sets.
+type MultiSetWriter interface {
+	ExpectSets(bool)
+}